@@ -2,7 +2,9 @@ package api
 
 import (
 	"fmt"
+	"regexp"
 	"sync"
+	"time"
 
 	"github.com/ch3lo/overlord/cluster"
 	"github.com/ch3lo/overlord/configuration"
@@ -12,6 +14,8 @@ import (
 	"github.com/ch3lo/overlord/monitor"
 	"github.com/ch3lo/overlord/notification"
 	"github.com/ch3lo/overlord/notification/factory"
+	"github.com/ch3lo/overlord/store"
+	storeFactory "github.com/ch3lo/overlord/store/factory"
 )
 
 type appContext struct {
@@ -21,6 +25,7 @@ type appContext struct {
 	broadcaster    report.Broadcast
 	clusters       map[string]*cluster.Cluster
 	appManagers    map[string]*service.Manager
+	store          store.Store
 }
 
 func newContext(config *configuration.Configuration) *appContext {
@@ -33,6 +38,9 @@ func newContext(config *configuration.Configuration) *appContext {
 	app.setupBroadcaster(config.Notification)
 	app.setupClusters(config.Clusters)
 	app.setupServiceUpdater(config.Updater)
+	app.setupStore(config.Store)
+	app.restoreServiceManagers()
+	app.registerConfiguredServices(config.Services)
 
 	return app
 }
@@ -47,6 +55,7 @@ func (o *appContext) setupBroadcaster(config configuration.Notification) {
 			continue
 		}
 
+		// Los notificadores compilan y validan sus templates al crearse
 		notification, err := factory.Create(params.NotificationType, key, params.Config)
 		if err != nil {
 			logger.Instance().Fatalf("Error al crear la notificacion %s. %s", key, err.Error())
@@ -64,12 +73,62 @@ func (o *appContext) setupBroadcaster(config configuration.Notification) {
 		logger.Instance().Warnln("No hay notificadores configurados")
 	}
 
+	if err := broadcaster.SetRoutes(notificationRoutes(config)); err != nil {
+		logger.Instance().Fatalf("No se pudieron configurar las rutas de notificacion: %s", err.Error())
+	}
+
 	o.broadcaster = broadcaster
 }
 
+// notificationRoutes construye las rutas de notificacion omitiendo los notificadores deshabilitados
+func notificationRoutes(config configuration.Notification) []report.Route {
+	var routes []report.Route
+	for i, v := range config.Routes {
+		route := report.Route{
+			Clusters:   v.Clusters,
+			Severities: v.Severities,
+			Checks:     v.Checks,
+			Continue:   v.Continue,
+		}
+
+		if v.App != "" {
+			appRegexp, err := regexp.Compile(v.App)
+			if err != nil {
+				logger.Instance().Fatalf("La expresion regular de la ruta de notificacion %d es invalida: %s", i, err.Error())
+			}
+			route.App = appRegexp
+		}
+
+		for _, p := range v.Providers {
+			if provider, ok := config.Providers[p]; ok && provider.Disabled {
+				logger.Instance().Warnf("La ruta de notificacion %d omite el notificador deshabilitado %s", i, p)
+				continue
+			}
+			route.Providers = append(route.Providers, p)
+		}
+
+		// Una ruta cuyos notificadores estan todos deshabilitados se omite para que las alertas
+		// sigan a las rutas siguientes en vez de descartarse
+		if len(v.Providers) > 0 && len(route.Providers) == 0 {
+			logger.Instance().Warnf("La ruta de notificacion %d no tiene notificadores habilitados, se omite", i)
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// historyPath es el segmento de la ruta del historial de un servicio, que tiene prioridad
+// sobre la consulta de un cluster con el mismo id
+const historyPath = "history"
+
 // setupClusters inicia el cluster, mapeando el cluster el id del cluster como key
 func (o *appContext) setupClusters(config map[string]configuration.Cluster) {
 	for key := range config {
+		if key == historyPath {
+			logger.Instance().Fatalf("El id de cluster %s esta reservado", key)
+		}
+
 		c, err := cluster.NewCluster(key, config[key])
 		if err != nil {
 			switch err.(type) {
@@ -97,6 +156,84 @@ func (o *appContext) setupServiceUpdater(config configuration.Updater) {
 	o.serviceUpdater = su
 }
 
+// setupStore inicia el almacen donde se persisten los servicios registrados
+// Si no se configura un tipo se utiliza el store basado en archivo
+func (o *appContext) setupStore(config configuration.Store) {
+	storeType := config.StoreType
+	if storeType == "" {
+		storeType = "file"
+	}
+
+	s, err := storeFactory.Create(storeType, config.Config)
+	if err != nil {
+		logger.Instance().Fatalf("Error al crear el store %s. %s", storeType, err.Error())
+	}
+
+	logger.Instance().Infof("Se creo el store de servicios de tipo %s", storeType)
+	o.store = s
+}
+
+// restoreServiceManagers vuelve a registrar los managers persistidos en el store
+// Los registros que no se puedan recrear se omiten para no impedir el inicio
+func (o *appContext) restoreServiceManagers() {
+	registered, err := o.store.FindAll()
+	if err != nil {
+		logger.Instance().Fatalf("No se pudieron obtener los servicios del store: %s", err.Error())
+	}
+
+	for _, params := range registered {
+		o.serviceMux.Lock()
+		_, err := o.registerServiceManager(params)
+		o.serviceMux.Unlock()
+		if err != nil {
+			logger.Instance().Errorf("No se pudo restaurar el manager %s#%s: %s", params.ID, params.Version, err.Error())
+			continue
+		}
+		logger.Instance().Infof("Se restauro el manager %s#%s", params.ID, params.Version)
+	}
+}
+
+// registerConfiguredServices registra los managers declarados en la configuracion
+// No se persisten en el store, la configuracion es su origen. Si un servicio ya fue
+// restaurado desde el store se mantiene el registro existente
+func (o *appContext) registerConfiguredServices(services []configuration.Service) {
+	for _, v := range services {
+		params := service.Parameters{
+			ID:      v.ID,
+			Version: v.Version,
+			Constraints: service.ConstraintsParams{
+				ImageName:              v.ImageName,
+				MinInstancesPerCluster: v.MinInstancesPerCluster,
+				Criteria:               v.Criteria,
+				Checks:                 checkParams(v.Checks),
+			},
+		}
+
+		o.serviceMux.Lock()
+		_, err := o.registerServiceManager(params)
+		o.serviceMux.Unlock()
+		if err != nil {
+			logger.Instance().Errorf("No se pudo registrar el manager %s#%s de la configuracion: %s", v.ID, v.Version, err.Error())
+			continue
+		}
+		logger.Instance().Infof("Se registro el manager %s#%s de la configuracion", v.ID, v.Version)
+	}
+}
+
+// checkParams mapea los chequeos de la configuracion a los parametros de chequeo de un manager
+func checkParams(checkers []configuration.Checker) []service.CheckParams {
+	var checks []service.CheckParams
+	for _, v := range checkers {
+		checks = append(checks, service.CheckParams{
+			Type:                v.Type,
+			MinHosts:            v.MinHosts,
+			MinHostsPerCluster:  v.MinHostsPerCluster,
+			MaxInstancesPerHost: v.MaxInstancesPerHost,
+		})
+	}
+	return checks
+}
+
 func (o *appContext) clusterIds() []string {
 	var names []string
 	for k := range o.clusters {
@@ -109,28 +246,96 @@ func (o *appContext) clusterIds() []string {
 // Si el contenedor ya existia se omite su creación y se procede a registrar
 // las versiones de los servicios.
 // Si no se puede registrar una nueva version se retornara un error.
+// Los criterios y chequeos se validan antes de persistir el registro para no guardar
+// registros que no se podran restaurar
 func (o *appContext) RegisterServiceManager(params service.Parameters) (*service.Manager, error) {
 	o.serviceMux.Lock()
 	defer o.serviceMux.Unlock()
 
-	criteria, err := params.BuildCriteria()
+	if err := o.managerAlreadyExist(params); err != nil {
+		return nil, err
+	}
+
+	sm, criteria, err := o.newServiceManager(params)
 	if err != nil {
 		return nil, err
 	}
 
-	sm, err := service.NewServiceManager(o.clusterIds(), o.config.Manager.Check, o.broadcaster, params)
+	if err := o.store.Save(params); err != nil {
+		return nil, err
+	}
+
+	o.startServiceManager(sm, criteria)
+	return sm, nil
+}
+
+// registerServiceManager crea el manager, lo subscribe al ServiceUpdater y comienza sus chequeos
+// Se debe llamar con serviceMux tomado
+func (o *appContext) registerServiceManager(params service.Parameters) (*service.Manager, error) {
+	if err := o.managerAlreadyExist(params); err != nil {
+		return nil, err
+	}
+
+	sm, criteria, err := o.newServiceManager(params)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, ok := o.appManagers[sm.ID()]; ok {
-		return nil, &service.ManagerAlreadyExist{Service: sm.ID(), Version: params.Version}
+	o.startServiceManager(sm, criteria)
+	return sm, nil
+}
+
+// managerAlreadyExist retorna ManagerAlreadyExist si ya hay un manager registrado para la version
+// Se debe llamar con serviceMux tomado
+func (o *appContext) managerAlreadyExist(params service.Parameters) error {
+	if _, ok := o.appManagers[params.ID+"#"+params.Version]; ok {
+		return &service.ManagerAlreadyExist{Service: params.ID, Version: params.Version}
 	}
+	return nil
+}
 
+// newServiceManager construye y valida el criterio y el manager de los parametros sin registrarlos
+func (o *appContext) newServiceManager(params service.Parameters) (*service.Manager, monitor.ServiceChangeCriteria, error) {
+	criteria, err := params.BuildCriteria()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sm, err := service.NewServiceManager(o.clusterIds(), o.config.Manager, o.broadcaster, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sm, criteria, nil
+}
+
+// startServiceManager subscribe el manager al ServiceUpdater, comienza sus chequeos y lo registra
+// Se debe llamar con serviceMux tomado
+func (o *appContext) startServiceManager(sm *service.Manager, criteria monitor.ServiceChangeCriteria) {
 	o.serviceUpdater.Register(sm, criteria)
 	sm.StartCheck()
 
 	o.appManagers[sm.ID()] = sm
+}
+
+// UnregisterServiceManager detiene el monitoreo de una version de un servicio
+// Se remueve el manager como subscriptor del ServiceUpdater y se detienen sus chequeos
+func (o *appContext) UnregisterServiceManager(appId string, version string) (*service.Manager, error) {
+	o.serviceMux.Lock()
+	defer o.serviceMux.Unlock()
+
+	sm, ok := o.appManagers[appId+"#"+version]
+	if !ok {
+		return nil, &service.ManagerNotFound{Service: appId, Version: version}
+	}
+
+	if err := o.store.Delete(appId, version); err != nil {
+		return nil, err
+	}
+
+	o.serviceUpdater.Remove(sm)
+	sm.StopCheck()
+
+	delete(o.appManagers, sm.ID())
 	return sm, nil
 }
 
@@ -147,15 +352,48 @@ func (o *appContext) registerApplication(params service.Parameters) *service.App
 	return app
 }*/
 
-func (o *appContext) GetApplications() map[string]*service.AppMajor {
-	apps := make(map[string]*service.AppMajor)
+// GetApplications retorna los managers registrados agrupados por el ID de la aplicacion
+func (o *appContext) GetApplications() map[string][]*service.Manager {
+	o.serviceMux.Lock()
+	defer o.serviceMux.Unlock()
 
+	apps := make(map[string][]*service.Manager)
 	for _, v := range o.appManagers {
-		apps[v.App.ID] = v.App
+		apps[v.App.ID] = append(apps[v.App.ID], v)
 	}
 	return apps
 }
 
+// GetApplication retorna los managers de todas las versiones registradas de una aplicacion
+func (o *appContext) GetApplication(appId string) []*service.Manager {
+	o.serviceMux.Lock()
+	defer o.serviceMux.Unlock()
+
+	var managers []*service.Manager
+	for _, v := range o.appManagers {
+		if v.App.ID == appId {
+			managers = append(managers, v)
+		}
+	}
+	return managers
+}
+
+// Shutdown detiene ordenadamente el monitoreo de servicios, los chequeos de todos los
+// managers y espera hasta timeout a que se entreguen las notificaciones pendientes
+func (o *appContext) Shutdown(timeout time.Duration) {
+	o.serviceUpdater.Stop()
+
+	o.serviceMux.Lock()
+	for _, sm := range o.appManagers {
+		sm.StopCheck()
+	}
+	o.serviceMux.Unlock()
+
+	if o.broadcaster.Stop(timeout) {
+		logger.Instance().Infoln("Se entregaron todas las notificaciones pendientes")
+	}
+}
+
 // NotificationDisabled error generado cuando un notificador no esta habilitado
 type NotificationDisabled struct {
 	Name string
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/ch3lo/overlord/configuration"
+	"github.com/ch3lo/overlord/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationRoutes(t *testing.T) {
+	assert := assert.New(t)
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	config := configuration.Notification{
+		Providers: map[string]configuration.NotificationProvider{
+			"email": {NotificationType: "email"},
+			"pager": {NotificationType: "http", Disabled: true},
+		},
+		Routes: []configuration.NotificationRoute{
+			{Severities: []string{"critical"}, Providers: []string{"pager"}},
+			{Severities: []string{"critical"}, Providers: []string{"pager", "email"}},
+			{App: "^app", Providers: []string{"email"}},
+		},
+	}
+
+	routes := notificationRoutes(config)
+	assert.Len(routes, 2)
+	assert.Equal([]string{"email"}, routes[0].Providers)
+	assert.Equal([]string{"critical"}, routes[0].Severities)
+	assert.Equal("^app", routes[1].App.String())
+}
@@ -5,6 +5,22 @@ import "fmt"
 type apiError interface {
 	GetCode() int
 	GetMessage() string
+	GetDetail() string
+}
+
+// errorResponse es el envelope con el que se responden todos los errores de la API
+type errorResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func newErrorResponse(err apiError) errorResponse {
+	return errorResponse{
+		Status:  err.GetCode(),
+		Message: err.GetMessage(),
+		Detail:  err.GetDetail(),
+	}
 }
 
 type codeAndMessage struct {
@@ -20,6 +36,10 @@ func (e codeAndMessage) GetMessage() string {
 	return e.Message
 }
 
+func (e codeAndMessage) GetDetail() string {
+	return ""
+}
+
 func (e codeAndMessage) Error() string {
 	return fmt.Sprintf("%v: %v", e.Code, e.Message)
 }
@@ -30,7 +50,7 @@ type ElementAlreadyExists struct {
 
 func NewElementAlreadyExists() ElementAlreadyExists {
 	return ElementAlreadyExists{
-		codeAndMessage{Code: 400, Message: "Elemento ya existe"},
+		codeAndMessage{Code: 409, Message: "Elemento ya existe"},
 	}
 }
 
@@ -40,7 +60,7 @@ type ServiceNotFound struct {
 
 func NewServiceNotFound() ServiceNotFound {
 	return ServiceNotFound{
-		codeAndMessage{Code: 400, Message: "Servicio no existe"},
+		codeAndMessage{Code: 404, Message: "Servicio no existe"},
 	}
 }
 
@@ -51,11 +71,15 @@ type SerializationError struct {
 
 func NewSerializationError(d string) SerializationError {
 	return SerializationError{
-		codeAndMessage{Code: 400, Message: "Servicio no existe"},
+		codeAndMessage{Code: 400, Message: "Error de serializacion"},
 		d,
 	}
 }
 
+func (e SerializationError) GetDetail() string {
+	return e.Detail
+}
+
 type UnknownError struct {
 	codeAndMessage
 	detail string
@@ -68,6 +92,10 @@ func NewUnknownError(d string) UnknownError {
 	}
 }
 
+func (e UnknownError) GetDetail() string {
+	return e.detail
+}
+
 type ImageNameRegexpError struct {
 	codeAndMessage
 	Detail string `json:"detail"`
@@ -79,3 +107,71 @@ func NewImageNameRegexpError(d string) ImageNameRegexpError {
 		d,
 	}
 }
+
+func (e ImageNameRegexpError) GetDetail() string {
+	return e.Detail
+}
+
+type InvalidFilterError struct {
+	codeAndMessage
+	Detail string `json:"detail"`
+}
+
+func NewInvalidFilterError(d string) InvalidFilterError {
+	return InvalidFilterError{
+		codeAndMessage{Code: 400, Message: "Filtro invalido"},
+		d,
+	}
+}
+
+func (e InvalidFilterError) GetDetail() string {
+	return e.Detail
+}
+
+type InvalidCriteriaError struct {
+	codeAndMessage
+	Detail string `json:"detail"`
+}
+
+func NewInvalidCriteriaError(d string) InvalidCriteriaError {
+	return InvalidCriteriaError{
+		codeAndMessage{Code: 400, Message: "Expresion de criterios invalida"},
+		d,
+	}
+}
+
+func (e InvalidCriteriaError) GetDetail() string {
+	return e.Detail
+}
+
+type MissingCriteriaError struct {
+	codeAndMessage
+	Detail string `json:"detail"`
+}
+
+func NewMissingCriteriaError(d string) MissingCriteriaError {
+	return MissingCriteriaError{
+		codeAndMessage{Code: 400, Message: "Se requiere image_name o criteria"},
+		d,
+	}
+}
+
+func (e MissingCriteriaError) GetDetail() string {
+	return e.Detail
+}
+
+type InvalidCheckError struct {
+	codeAndMessage
+	Detail string `json:"detail"`
+}
+
+func NewInvalidCheckError(d string) InvalidCheckError {
+	return InvalidCheckError{
+		codeAndMessage{Code: 400, Message: "Chequeo invalido"},
+		d,
+	}
+}
+
+func (e InvalidCheckError) GetDetail() string {
+	return e.Detail
+}
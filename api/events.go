@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ch3lo/overlord/api/types"
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/monitor"
+)
+
+// eventsBufferSize cantidad de eventos que se encolan por cliente antes de descartarlos
+const eventsBufferSize = 256
+
+// eventsKeepAlive cada cuanto se envia un comentario para mantener viva la conexion
+const eventsKeepAlive = 30 * time.Second
+
+var eventSubscribersCount uint64
+
+// eventSubscriber implementa monitor.ServiceUpdaterSubscriber para transmitir
+// los cambios de servicios a un cliente conectado via Server-Sent Events
+type eventSubscriber struct {
+	id     string
+	events chan types.ServiceEvent
+}
+
+func newEventSubscriber() *eventSubscriber {
+	return &eventSubscriber{
+		id:     "events#" + strconv.FormatUint(atomic.AddUint64(&eventSubscribersCount, 1), 10),
+		events: make(chan types.ServiceEvent, eventsBufferSize),
+	}
+}
+
+// ID implementa ServiceUpdaterSubscriber
+func (s *eventSubscriber) ID() string {
+	return s.id
+}
+
+// Update implementa ServiceUpdaterSubscriber
+// Nunca bloquea al ServiceUpdater, si el cliente no consume los eventos a tiempo se descartan
+func (s *eventSubscriber) Update(data map[string]*monitor.ServiceUpdaterData) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		select {
+		case s.events <- newServiceEvent(k, data[k]):
+		default:
+			logger.Instance().WithField("subscriber", s.id).Warnf("Se descarto el evento del servicio %s", k)
+		}
+	}
+}
+
+func newServiceEvent(serviceID string, data *monitor.ServiceUpdaterData) types.ServiceEvent {
+	date := data.LastUpdate()
+	event := types.ServiceEvent{
+		ServiceID:      serviceID,
+		Cluster:        data.ClusterID(),
+		PreviousStatus: data.PreviousAction().String(),
+		Status:         data.LastAction().String(),
+		Flapping:       data.Flapping(),
+		Date:           &date,
+	}
+
+	if data.Origin() != nil {
+		event.ImageName = data.Origin().ImageName
+		event.ImageTag = data.Origin().ImageTag
+	}
+
+	if data.PreviousOrigin() != nil {
+		event.PreviousImageTag = data.PreviousOrigin().ImageTag
+	}
+
+	event.Changes = newServiceChanges(data.Changes())
+
+	return event
+}
+
+func newServiceChanges(changes []monitor.ServiceChange) []types.ServiceChange {
+	var apiChanges []types.ServiceChange
+	for _, change := range changes {
+		apiChanges = append(apiChanges, types.ServiceChange{
+			Type:     change.Type.String(),
+			Instance: change.InstanceID,
+			Previous: change.Previous,
+			Current:  change.Current,
+		})
+	}
+	return apiChanges
+}
+
+// allCriteria aplica todos los criterios en secuencia, si no hay criterios no filtra
+type allCriteria []monitor.ServiceChangeCriteria
+
+func (c allCriteria) MeetCriteria(elements map[string]*monitor.ServiceUpdaterData) map[string]*monitor.ServiceUpdaterData {
+	filtered := elements
+	for _, criteria := range c {
+		filtered = criteria.MeetCriteria(filtered)
+	}
+	return filtered
+}
+
+// eventCriteria construye el criterio de filtro de eventos a partir de los parametros de la query
+// image: expresion regular sobre <nombre de imagen>:<tag>
+// status: estado del servicio (added, updated, removed, updating, purged)
+// cluster: lista de clusters separados por coma
+// flapping: true o false segun si el servicio esta intermitente
+// criteria: expresion interpretada por monitor.ParseCriteria
+func eventCriteria(query url.Values) (monitor.ServiceChangeCriteria, error) {
+	var criteria allCriteria
+
+	if image := query.Get("image"); image != "" {
+		imageRegexp, err := regexp.Compile(image)
+		if err != nil {
+			return nil, NewImageNameRegexpError(err.Error())
+		}
+		criteria = append(criteria, &monitor.ImageNameAndImageTagRegexpCriteria{FullImageNameRegexp: imageRegexp})
+	}
+
+	if status := query.Get("status"); status != "" {
+		s, err := monitor.ParseServiceDataStatus(status)
+		if err != nil {
+			return nil, NewInvalidFilterError(err.Error())
+		}
+		criteria = append(criteria, &monitor.StatusCriteria{Status: s})
+	}
+
+	if cluster := query.Get("cluster"); cluster != "" {
+		criteria = append(criteria, &monitor.ClusterCriteria{Clusters: strings.Split(cluster, ",")})
+	}
+
+	if flapping := query.Get("flapping"); flapping != "" {
+		f, err := strconv.ParseBool(flapping)
+		if err != nil {
+			return nil, NewInvalidFilterError(err.Error())
+		}
+		criteria = append(criteria, &monitor.FlappingCriteria{Flapping: f})
+	}
+
+	if expression := query.Get("criteria"); expression != "" {
+		c, err := monitor.ParseCriteria(expression)
+		if err != nil {
+			return nil, NewInvalidCriteriaError(err.Error())
+		}
+		criteria = append(criteria, c)
+	}
+
+	return criteria, nil
+}
+
+func getEvents(c *appContext, w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return NewUnknownError("El servidor no soporta streaming")
+	}
+
+	criteria, err := eventCriteria(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Solo se transmiten los cambios detectados luego de la conexion
+	sub := newEventSubscriber()
+	c.serviceUpdater.Watch(sub, criteria)
+	defer c.serviceUpdater.Remove(sub)
+
+	streamEvents(r.Context(), w, flusher, sub)
+	return nil
+}
+
+// streamEvents escribe los eventos del subscriptor en formato Server-Sent Events hasta que
+// se cancele ctx. Periodicamente envia un comentario para mantener viva la conexion
+func streamEvents(ctx context.Context, w io.Writer, flusher http.Flusher, sub *eventSubscriber) {
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case event := <-sub.events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Instance().WithField("subscriber", sub.ID()).Errorf("No se pudo serializar el evento: %s", err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Status, data)
+			flusher.Flush()
+		}
+	}
+}
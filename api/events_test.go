@@ -0,0 +1,172 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ch3lo/overlord/monitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// syncWriter permite leer lo escrito por streamEvents mientras se sigue escribiendo
+type syncWriter struct {
+	mux sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *syncWriter) Flush() {}
+
+func (w *syncWriter) String() string {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.buf.String()
+}
+
+// captureSubscriber guarda los servicios que recibe al registrarse
+type captureSubscriber struct {
+	services map[string]*monitor.ServiceUpdaterData
+}
+
+func (s *captureSubscriber) ID() string { return "capture" }
+
+func (s *captureSubscriber) Update(data map[string]*monitor.ServiceUpdaterData) { s.services = data }
+
+func (suite *HandlersSuite) TestEventSubscriber() {
+	assert := assert.New(suite.T())
+
+	sub := newEventSubscriber()
+	assert.True(strings.HasPrefix(sub.ID(), "events#"))
+	assert.NotEqual(sub.ID(), newEventSubscriber().ID())
+
+	sub.Update(map[string]*monitor.ServiceUpdaterData{
+		"srv2": monitor.NewServiceUpdaterData(),
+		"srv1": monitor.NewServiceUpdaterData(),
+	})
+	assert.Len(sub.events, 2)
+	event := <-sub.events
+	assert.Equal("srv1", event.ServiceID)
+	assert.Equal("ServiceAdded", event.Status)
+	assert.Equal("srv2", (<-sub.events).ServiceID)
+
+	// Si el cliente no consume los eventos se descartan sin bloquear
+	data := make(map[string]*monitor.ServiceUpdaterData)
+	for i := 0; i <= eventsBufferSize; i++ {
+		data[fmt.Sprintf("srv%d", i)] = monitor.NewServiceUpdaterData()
+	}
+	sub.Update(data)
+	assert.Len(sub.events, eventsBufferSize)
+}
+
+func (suite *HandlersSuite) TestEventCriteria() {
+	assert := assert.New(suite.T())
+
+	// Sin monitoreo en curso los servicios restaurados no cambian, por lo que se pueden retener
+	capture := &captureSubscriber{}
+	suite.ctx.serviceUpdater.Register(capture, allCriteria{})
+	services := capture.services
+
+	criteria, err := eventCriteria(url.Values{})
+	assert.Nil(err)
+	assert.Len(criteria.MeetCriteria(services), 1)
+
+	filters := []struct {
+		field    string
+		value    string
+		expected int
+	}{
+		{"image", "^registry.com/app:v1", 1},
+		{"image", "^other", 0},
+		{"status", "added", 1},
+		{"status", "purged", 0},
+		{"cluster", "dal,wdc", 1},
+		{"cluster", "dal", 0},
+		{"flapping", "false", 1},
+		{"criteria", "host == thor1", 1},
+		{"criteria", "cluster == wdc && !(tag == v1.0)", 0},
+	}
+	for _, filter := range filters {
+		criteria, err := eventCriteria(url.Values{filter.field: {filter.value}})
+		assert.Nil(err, filter.value)
+		assert.Len(criteria.MeetCriteria(services), filter.expected, filter.value)
+	}
+
+	_, err = eventCriteria(url.Values{"image": {"("}})
+	assert.IsType(ImageNameRegexpError{}, err)
+	_, err = eventCriteria(url.Values{"status": {"unknown"}})
+	assert.IsType(InvalidFilterError{}, err)
+	_, err = eventCriteria(url.Values{"flapping": {"quizas"}})
+	assert.IsType(InvalidFilterError{}, err)
+	_, err = eventCriteria(url.Values{"criteria": {"cluster =="}})
+	assert.IsType(InvalidCriteriaError{}, err)
+}
+
+func (suite *HandlersSuite) TestStreamEvents() {
+	assert := assert.New(suite.T())
+
+	sub := newEventSubscriber()
+	sub.Update(map[string]*monitor.ServiceUpdaterData{"srv1": monitor.NewServiceUpdaterData()})
+
+	w := &syncWriter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		streamEvents(ctx, w, w, sub)
+		close(done)
+	}()
+
+	assert.Eventually(func() bool {
+		return strings.Contains(w.String(), "event: ServiceAdded\ndata: {\"service_id\":\"srv1\"")
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func (suite *HandlersSuite) TestGetEvents() {
+	assert := assert.New(suite.T())
+
+	suite.assertError(suite.request("GET", "/api/v1/events?status=unknown", ""), http.StatusBadRequest, "Filtro invalido")
+
+	server := httptest.NewServer(suite.router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", server.URL+"/api/v1/events", nil)
+	suite.Require().Nil(err)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	suite.Require().Nil(err)
+	defer resp.Body.Close()
+
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	// Al conectarse no se reenvian los servicios conocidos, como srv1
+	lines := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		if scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	select {
+	case line := <-lines:
+		assert.Fail("No se esperaban eventos al conectarse", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
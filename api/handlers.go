@@ -3,11 +3,15 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
+	"time"
 
 	valid "github.com/asaskevich/govalidator"
 	"github.com/ch3lo/overlord/api/types"
 	"github.com/ch3lo/overlord/logger"
 	"github.com/ch3lo/overlord/manager/service"
+	"github.com/ch3lo/overlord/monitor"
+	"github.com/gorilla/mux"
 	"github.com/thoas/stats"
 	"github.com/unrolled/render"
 )
@@ -22,25 +26,39 @@ type errorHandler struct {
 func (eh errorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := eh.handler(eh.appCtx, w, r); err != nil {
 		logger.Instance().Errorln(err)
-		if err2, ok := err.(apiError); ok {
-			jsonRenderer(w, err2)
-			return
+		apiErr, ok := err.(apiError)
+		if !ok {
+			apiErr = NewUnknownError(err.Error())
 		}
-		jsonRenderer(w, NewUnknownError(err.Error()))
+		jsonStatusRenderer(w, apiErr.GetCode(), newErrorResponse(apiErr))
 	}
 }
 
 type statsHandler struct {
 	*stats.Stats
+	appCtx *appContext
+}
+
+// statsResponse agrega a las estadisticas http las del monitoreo de servicios
+type statsResponse struct {
+	*stats.Data
+	Updater monitor.UpdaterStats `json:"updater"`
 }
 
 func (sh *statsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	jsonRenderer(w, sh.Data())
+	jsonRenderer(w, &statsResponse{
+		Data:    sh.Data(),
+		Updater: sh.appCtx.serviceUpdater.Stats(),
+	})
 }
 
 func jsonRenderer(w http.ResponseWriter, i interface{}) {
+	jsonStatusRenderer(w, http.StatusOK, i)
+}
+
+func jsonStatusRenderer(w http.ResponseWriter, status int, i interface{}) {
 	rend := render.New()
-	rend.JSON(w, http.StatusOK, i)
+	rend.JSON(w, status, i)
 }
 
 type Response struct {
@@ -49,40 +67,112 @@ type Response struct {
 }
 
 func getServices(c *appContext, w http.ResponseWriter, r *http.Request) error {
-	//	servicesList := c.GetApplications()
-	var apiServices []types.Application /*
-		for _, srv := range servicesList {
-			var apiVersions []types.AppVersion
-			for _, v := range srv.Managers {
-				var instances []types.Instance
-				for _, instance := range v.GetInstances() {
-					instances = append(instances, types.Instance{
-						Id:           instance.ID,
-						CreationDate: &instance.CreationDate,
-						Cluster:      instance.ClusterID,
-					})
-				}
-
-				apiVersions = append(apiVersions, types.AppVersion{
-					Version:      v.Version,
-					CreationDate: &srv.CreationDate,
-					ImageName:    v.ImageName,
-					ImageTag:     v.ImageTag,
-					Instances:    instances,
-				})
-			}
-			apiServices = append(apiServices, types.Application{
-				Id:           srv.ID,
-				CreationDate: &srv.CreationDate,
-				Versions:     apiVersions,
-			})
-		}*/
+	servicesList := c.GetApplications()
+
+	appIds := make([]string, 0, len(servicesList))
+	for k := range servicesList {
+		appIds = append(appIds, k)
+	}
+	sort.Strings(appIds)
+
+	apiServices := make([]types.Application, 0, len(appIds))
+	for _, appId := range appIds {
+		apiServices = append(apiServices, newApplication(appId, servicesList[appId]))
+	}
 
 	jsonRenderer(w, &Response{Status: http.StatusOK, Data: apiServices})
 	return nil
+}
+
+// newApplication construye la representacion de una aplicacion a partir de los managers
+// de cada una de sus versiones mayores
+func newApplication(appId string, managers []*service.Manager) types.Application {
+	sort.Sort(byVersion(managers))
+
+	app := types.Application{Id: appId}
+	for _, m := range managers {
+		if app.CreationDate == nil || m.App.CreationDate.Before(*app.CreationDate) {
+			creationDate := m.App.CreationDate
+			app.CreationDate = &creationDate
+		}
+		app.Versions = append(app.Versions, newAppMajorVersion(m))
+	}
+	return app
+}
+
+// newAppMajorVersion construye la representacion de una version mayor a partir de su manager
+func newAppMajorVersion(m *service.Manager) types.AppMajorVersion {
+	clusterCheck := make(map[string]types.ClusterCheck)
+	for k, v := range m.App.Constraints.MinInstancesPerCluster {
+		clusterCheck[k] = types.ClusterCheck{Instances: v}
+	}
+
+	var instances []types.Instance
+	for _, instance := range m.GetInstances() {
+		creationDate := instance.CreationDate
+		instances = append(instances, types.Instance{
+			Id:           instance.ID,
+			CreationDate: &creationDate,
+			Cluster:      instance.ClusterID,
+			Address:      instance.Host,
+			ImageName:    instance.ImageName,
+			ImageTag:     instance.ImageTag,
+			Healthy:      instance.Healthy,
+		})
+	}
+	sort.Sort(byInstanceId(instances))
+
+	status := m.Status()
+	checkStatus := &types.CheckStatus{
+		Success:          status.Success,
+		Failed:           status.Failed,
+		ConsecutiveFails: status.ConsecutiveFails,
+		Threshold:        status.Threshold,
+		Alert:            status.Alert.String(),
+	}
+	if !status.AlertSince.IsZero() {
+		alertSince := status.AlertSince
+		checkStatus.AlertSince = &alertSince
+	}
+	if !status.LastResult.Date.IsZero() {
+		lastCheck := status.LastResult.Date
+		checkStatus.LastCheck = &lastCheck
+	}
+	for _, f := range status.LastResult.Failures {
+		checkStatus.Failures = append(checkStatus.Failures, types.CheckFailure{
+			Check:     f.Check,
+			Cluster:   f.Cluster,
+			Host:      f.Host,
+			Observed:  f.Observed,
+			Threshold: f.Threshold,
+		})
+	}
 
+	creationDate := m.CreationDate
+	return types.AppMajorVersion{
+		Version:      m.Version,
+		CreationDate: &creationDate,
+		ImageName:    m.App.Constraints.ImageName,
+		Criteria:     m.App.Constraints.Criteria,
+		Checks:       newCheckMappers(m.App.Constraints.Checks),
+		Instances:    instances,
+		ClusterCheck: clusterCheck,
+		CheckStatus:  checkStatus,
+	}
 }
 
+type byVersion []*service.Manager
+
+func (v byVersion) Len() int           { return len(v) }
+func (v byVersion) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v byVersion) Less(i, j int) bool { return v[i].Version < v[j].Version }
+
+type byInstanceId []types.Instance
+
+func (v byInstanceId) Len() int           { return len(v) }
+func (v byInstanceId) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v byInstanceId) Less(i, j int) bool { return v[i].Id < v[j].Id }
+
 func putService(c *appContext, w http.ResponseWriter, r *http.Request) error {
 	var appReq types.AppRequest
 	if err := json.NewDecoder(r.Body).Decode(&appReq); err != nil {
@@ -95,29 +185,19 @@ func putService(c *appContext, w http.ResponseWriter, r *http.Request) error {
 	}
 	logger.Instance().Debugln("Se valido correctamente la estructura")
 
-	clusterCheck := make(map[string]int)
-	for k, v := range appReq.Constraints.ClusterCheck {
-		clusterCheck[k] = v.Instances
-	}
-
 	params := service.Parameters{
 		ID:      appReq.AppID,
 		Version: appReq.MajorVersion,
 		Constraints: service.ConstraintsParams{
 			ImageName:              appReq.Constraints.ImageName,
-			MinInstancesPerCluster: clusterCheck,
+			MinInstancesPerCluster: minInstancesPerCluster(appReq.Constraints.ClusterCheck),
+			Criteria:               appReq.Constraints.Criteria,
+			Checks:                 newCheckParams(appReq.Constraints.Checks),
 		},
 	}
 
 	if _, err := c.RegisterServiceManager(params); err != nil {
-		switch err.(type) {
-		case *service.AlreadyExist:
-			return NewElementAlreadyExists()
-		case *service.ImageNameRegexpError:
-			return NewImageNameRegexpError(err.Error())
-		default:
-			return NewUnknownError(err.Error())
-		}
+		return registrationError(err)
 	}
 
 	jsonRenderer(w, map[string]interface{}{
@@ -127,66 +207,222 @@ func putService(c *appContext, w http.ResponseWriter, r *http.Request) error {
 }
 
 func getServiceByServiceId(c *appContext, w http.ResponseWriter, r *http.Request) error {
-	/*	serviceId := c.Param("service_id")
-
-		bag := manager.ServicesBag()
-		for _, v := range bag {
-			if v.Id == serviceId {
-				rend.JSON(http.StatusOK, map[string]string{
-					"status":  http.StatusOK,
-					"service": types.Service{}})
-				return
-			}
-		}*/
-	return NewServiceNotFound()
+	serviceId := mux.Vars(r)["service_id"]
+
+	managers := c.GetApplication(serviceId)
+	if len(managers) == 0 {
+		return NewServiceNotFound()
+	}
+
+	jsonRenderer(w, &Response{Status: http.StatusOK, Data: newApplication(serviceId, managers)})
+	return nil
 }
 
 func getServiceByClusterAndServiceId(c *appContext, w http.ResponseWriter, r *http.Request) error {
-	/*cluster := c.Param("cluster")
-	serviceId := c.Param("service_id")
-
-		status, err := manager.GetService(cluster, serviceId)
-		if err != nil {
-			snf := &ServiceNotFound{}
-			rend.JSON(http.StatusOK, map[string]string{
-				"status":  snf.GetStatus(),
-				"message": err.Error()})
-			return
-		}*/
-	jsonRenderer(w, map[string]interface{}{
-		"status":  http.StatusOK,
-		"service": ""})
+	serviceId := mux.Vars(r)["service_id"]
+	clusterId := mux.Vars(r)["cluster"]
+
+	if _, ok := c.clusters[clusterId]; !ok {
+		return NewServiceNotFound()
+	}
+
+	managers := c.GetApplication(serviceId)
+	if len(managers) == 0 {
+		return NewServiceNotFound()
+	}
+
+	app := newApplication(serviceId, managers)
+	for k := range app.Versions {
+		var instances []types.Instance
+		for _, instance := range app.Versions[k].Instances {
+			if instance.Cluster == clusterId {
+				instances = append(instances, instance)
+			}
+		}
+		app.Versions[k].Instances = instances
+	}
+
+	jsonRenderer(w, &Response{Status: http.StatusOK, Data: app})
 	return nil
 }
 
 func putServiceVersionByServiceId(c *appContext, w http.ResponseWriter, r *http.Request) error {
-	//	serviceId := c.Param("service_id")
+	serviceId := mux.Vars(r)["service_id"]
 	var sv types.AppMajorVersion
 
 	if err := json.NewDecoder(r.Body).Decode(&sv); err != nil {
 		return NewSerializationError(err.Error())
 	}
-	/*
-		managedsv := &manager.ServiceVersion{Version: sv.Version}
-
-		if err := manager.RegisterServiceVersion(serviceId, managedsv); err != nil {
-			logger.Instance().Println(err)
-			if ce, ok := err.(*util.ElementAlreadyExists); ok {
-				rend.JSON(http.StatusOK, map[string]string{
-					"status":  ce.GetStatus(),
-					"message": ce.GetMessage()})
-			} else {
-				ue := &UnknownError{}
-				rend.JSON(http.StatusOK, map[string]string{
-					"status":  ue.GetStatus(),
-					"message": ue.GetMessage()})
-			}
-			return
+
+	if sv.Version == "" {
+		return NewSerializationError("La version es requerida")
+	}
+
+	if len(c.GetApplication(serviceId)) == 0 {
+		return NewServiceNotFound()
+	}
+
+	params := service.Parameters{
+		ID:      serviceId,
+		Version: sv.Version,
+		Constraints: service.ConstraintsParams{
+			ImageName:              sv.ImageName,
+			MinInstancesPerCluster: minInstancesPerCluster(sv.ClusterCheck),
+			Criteria:               sv.Criteria,
+			Checks:                 newCheckParams(sv.Checks),
+		},
+	}
+
+	sm, err := c.RegisterServiceManager(params)
+	if err != nil {
+		return registrationError(err)
+	}
+
+	jsonRenderer(w, map[string]interface{}{
+		"status":          http.StatusOK,
+		"service_version": newAppMajorVersion(sm)})
+	return nil
+}
+
+// registrationError mapea los errores de la registracion de un manager a los errores de la API
+func registrationError(err error) error {
+	switch err.(type) {
+	case *service.ManagerAlreadyExist:
+		return NewElementAlreadyExists()
+	case *service.ImageNameRegexpError:
+		return NewImageNameRegexpError(err.Error())
+	case *monitor.CriteriaExpressionError:
+		return NewInvalidCriteriaError(err.Error())
+	case *service.MissingCriteria:
+		return NewMissingCriteriaError(err.Error())
+	case *service.InvalidCheckParams:
+		return NewInvalidCheckError(err.Error())
+	default:
+		return NewUnknownError(err.Error())
+	}
+}
+
+// newCheckParams mapea los chequeos de la API a los parametros de chequeo de un manager
+func newCheckParams(checks []types.CheckMapper) []service.CheckParams {
+	var params []service.CheckParams
+	for _, v := range checks {
+		params = append(params, service.CheckParams{
+			Type:                v.Type,
+			MinHosts:            v.MinHosts,
+			MinHostsPerCluster:  v.MinHostsPerCluster,
+			MaxInstancesPerHost: v.MaxInstancesPerHost,
+		})
+	}
+	return params
+}
+
+// newCheckMappers mapea los parametros de chequeo de un manager a su representacion en la API
+func newCheckMappers(checks []service.CheckParams) []types.CheckMapper {
+	var mappers []types.CheckMapper
+	for _, v := range checks {
+		mappers = append(mappers, types.CheckMapper{
+			Type:                v.Type,
+			MinHosts:            v.MinHosts,
+			MinHostsPerCluster:  v.MinHostsPerCluster,
+			MaxInstancesPerHost: v.MaxInstancesPerHost,
+		})
+	}
+	return mappers
+}
+
+// minInstancesPerCluster mapea los chequeos por cluster de la API al minimo de instancias por cluster
+func minInstancesPerCluster(clusterCheck map[string]types.ClusterCheck) map[string]int {
+	minInstances := make(map[string]int)
+	for k, v := range clusterCheck {
+		minInstances[k] = v.Instances
+	}
+	return minInstances
+}
+
+func deleteServiceVersionByServiceId(c *appContext, w http.ResponseWriter, r *http.Request) error {
+	serviceId := mux.Vars(r)["service_id"]
+	version := mux.Vars(r)["version"]
+
+	sm, err := c.UnregisterServiceManager(serviceId, version)
+	if err != nil {
+		switch err.(type) {
+		case *service.ManagerNotFound:
+			return NewServiceNotFound()
+		default:
+			return NewUnknownError(err.Error())
 		}
-	*/
+	}
+
 	jsonRenderer(w, map[string]interface{}{
 		"status":          http.StatusOK,
-		"service_version": sv})
+		"service_version": newAppMajorVersion(sm)})
+	return nil
+}
+
+// getServiceHistory retorna el historial de transiciones de un servicio de los schedulers
+// Se puede acotar con los parametros from y to en formato RFC3339
+func getServiceHistory(c *appContext, w http.ResponseWriter, r *http.Request) error {
+	serviceId := mux.Vars(r)["service_id"]
+
+	var from, to time.Time
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return NewInvalidFilterError(err.Error())
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return NewInvalidFilterError(err.Error())
+		}
+	}
+
+	entries, ok := c.serviceUpdater.History(serviceId, from, to)
+	if !ok {
+		return NewServiceNotFound()
+	}
+
+	history := make([]types.HistoryEntry, 0, len(entries))
+	for _, v := range entries {
+		date := v.Date
+		history = append(history, types.HistoryEntry{
+			Date:           &date,
+			Cluster:        v.ClusterID,
+			PreviousStatus: v.PreviousStatus.String(),
+			Status:         v.Status.String(),
+			ImageTag:       v.ImageTag,
+			Changes:        newServiceChanges(v.Changes),
+		})
+	}
+
+	jsonRenderer(w, &Response{Status: http.StatusOK, Data: history})
+	return nil
+}
+
+// getNotifications retorna el estado de entrega de cada notificador registrado
+func getNotifications(c *appContext, w http.ResponseWriter, r *http.Request) error {
+	statuses := make([]types.NotificationStatus, 0)
+	for _, v := range c.broadcaster.Status() {
+		status := types.NotificationStatus{
+			ID:        v.ID,
+			Total:     v.Total,
+			Success:   v.Success,
+			Errors:    v.Errors,
+			Failed:    v.Failed,
+			LastError: v.LastError,
+		}
+		if !v.LastErrorDate.IsZero() {
+			lastErrorDate := v.LastErrorDate
+			status.LastErrorDate = &lastErrorDate
+		}
+		if !v.LastSuccess.IsZero() {
+			lastSuccess := v.LastSuccess
+			status.LastSuccess = &lastSuccess
+		}
+		statuses = append(statuses, status)
+	}
+
+	jsonRenderer(w, &Response{Status: http.StatusOK, Data: statuses})
 	return nil
 }
 
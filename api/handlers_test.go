@@ -0,0 +1,339 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ch3lo/overlord/api/types"
+	"github.com/ch3lo/overlord/cluster"
+	"github.com/ch3lo/overlord/configuration"
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/manager/report"
+	"github.com/ch3lo/overlord/manager/service"
+	"github.com/ch3lo/overlord/monitor"
+	"github.com/ch3lo/overlord/notification"
+	"github.com/ch3lo/overlord/store/file"
+	"github.com/gorilla/mux"
+	"github.com/latam-airlines/mesos-framework-factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/thoas/stats"
+)
+
+func TestHandlers(t *testing.T) {
+	suite.Run(t, new(HandlersSuite))
+}
+
+type fakeNotification struct{}
+
+func (n *fakeNotification) ID() string { return "fake" }
+
+func (n *fakeNotification) Notify(alert *notification.Alert) error { return nil }
+
+// historyDate es la fecha de la primera transicion restaurada de srv1
+var historyDate = time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC)
+
+type HandlersSuite struct {
+	suite.Suite
+	dir    string
+	ctx    *appContext
+	store  *file.Store
+	router *mux.Router
+}
+
+// SetupTest crea un contexto con dos clusters y el servicio srv1 con una instancia en wdc,
+// restaurado desde un respaldo del ServiceUpdater
+func (suite *HandlersSuite) SetupTest() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	dir, err := ioutil.TempDir("", "overlord-api")
+	suite.Require().Nil(err)
+	suite.dir = dir
+
+	snapshot := map[string]interface{}{
+		"srv1": map[string]interface{}{
+			"register_date": time.Now(),
+			"last_update":   time.Now(),
+			"last_action":   monitor.ServiceAdded,
+			"cluster_id":    "wdc",
+			"origin": &framework.ServiceInformation{
+				ID:        "srv1",
+				ImageName: "registry.com/app",
+				ImageTag:  "v1.0",
+				Instances: []*framework.Instance{
+					{ID: "instance1", Host: "thor1", Status: framework.InstanceUp},
+				},
+			},
+			"history": []monitor.HistoryEntry{
+				{Date: historyDate, ClusterID: "wdc", Status: monitor.ServiceAdded, ImageTag: "v0.9"},
+				{Date: historyDate.Add(time.Hour), ClusterID: "wdc", PreviousStatus: monitor.ServiceAdded, Status: monitor.ServiceUpdated, ImageTag: "v1.0",
+					Changes: []monitor.ServiceChange{{Type: monitor.ImageTagChanged, Previous: "v0.9", Current: "v1.0"}}},
+			},
+		},
+	}
+	data, err := json.Marshal(snapshot)
+	suite.Require().Nil(err)
+	snapshotFile := filepath.Join(dir, "updater.json")
+	suite.Require().Nil(ioutil.WriteFile(snapshotFile, data, 0644))
+
+	s, err := file.New(filepath.Join(dir, "services.json"))
+	suite.Require().Nil(err)
+	suite.store = s
+
+	broadcaster := report.NewBroadcaster(1, time.Millisecond, time.Millisecond)
+	suite.Require().Nil(broadcaster.Register(&fakeNotification{}))
+
+	clusters := map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}, "dal": &cluster.Cluster{}}
+	suite.ctx = &appContext{
+		config:         &configuration.Configuration{},
+		clusters:       clusters,
+		appManagers:    make(map[string]*service.Manager),
+		serviceUpdater: monitor.NewServiceUpdater(configuration.Updater{SnapshotFile: snapshotFile}, clusters),
+		broadcaster:    broadcaster,
+		store:          s,
+	}
+	suite.router = routes(suite.ctx, stats.New())
+}
+
+func (suite *HandlersSuite) TearDownTest() {
+	suite.ctx.Shutdown(time.Second)
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *HandlersSuite) request(method string, path string, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	suite.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode obtiene el campo data de una respuesta exitosa
+func (suite *HandlersSuite) decode(rec *httptest.ResponseRecorder, data interface{}) {
+	response := struct {
+		Status int         `json:"status"`
+		Data   interface{} `json:"data"`
+	}{Data: data}
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), &response))
+	suite.Equal(http.StatusOK, response.Status)
+}
+
+// assertError valida el codigo http y el envelope de una respuesta de error
+func (suite *HandlersSuite) assertError(rec *httptest.ResponseRecorder, status int, message string) {
+	assert := assert.New(suite.T())
+	assert.Equal(status, rec.Code)
+
+	var response errorResponse
+	assert.Nil(json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(status, response.Status)
+	assert.Equal(message, response.Message)
+}
+
+func (suite *HandlersSuite) putApp() {
+	rec := suite.request("PUT", "/api/v1/services/", `{"app_id":"app","app_major_version":"v1",
+		"constraints":{"image_name":"registry.com/app","cluster_check":{"wdc":{"instances":1}}}}`)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *HandlersSuite) TestGetServices() {
+	assert := assert.New(suite.T())
+
+	rec := suite.request("GET", "/api/v1/services/", "")
+	assert.Equal(http.StatusOK, rec.Code)
+	var apps []types.Application
+	suite.decode(rec, &apps)
+	assert.Len(apps, 0)
+
+	suite.putApp()
+	rec = suite.request("GET", "/api/v1/services/", "")
+	suite.decode(rec, &apps)
+	assert.Len(apps, 1)
+	assert.Equal("app", apps[0].Id)
+	assert.Len(apps[0].Versions, 1)
+	assert.Equal("v1", apps[0].Versions[0].Version)
+	assert.Equal(1, apps[0].Versions[0].ClusterCheck["wdc"].Instances)
+	assert.Len(apps[0].Versions[0].Instances, 1)
+	assert.Equal("instance1", apps[0].Versions[0].Instances[0].Id)
+}
+
+func (suite *HandlersSuite) TestGetServiceByServiceId() {
+	assert := assert.New(suite.T())
+	suite.putApp()
+
+	rec := suite.request("GET", "/api/v1/services/app", "")
+	assert.Equal(http.StatusOK, rec.Code)
+	var app types.Application
+	suite.decode(rec, &app)
+	assert.Equal("app", app.Id)
+	assert.Len(app.Versions, 1)
+
+	suite.assertError(suite.request("GET", "/api/v1/services/other", ""), http.StatusNotFound, "Servicio no existe")
+}
+
+func (suite *HandlersSuite) TestGetServiceByClusterAndServiceId() {
+	assert := assert.New(suite.T())
+	suite.putApp()
+
+	var app types.Application
+	suite.decode(suite.request("GET", "/api/v1/services/app/wdc", ""), &app)
+	assert.Len(app.Versions[0].Instances, 1)
+
+	app = types.Application{}
+	suite.decode(suite.request("GET", "/api/v1/services/app/dal", ""), &app)
+	assert.Len(app.Versions[0].Instances, 0)
+
+	suite.assertError(suite.request("GET", "/api/v1/services/app/ams", ""), http.StatusNotFound, "Servicio no existe")
+	suite.assertError(suite.request("GET", "/api/v1/services/other/wdc", ""), http.StatusNotFound, "Servicio no existe")
+}
+
+func (suite *HandlersSuite) TestPutService() {
+	assert := assert.New(suite.T())
+	suite.putApp()
+
+	registered, err := suite.store.FindAll()
+	assert.Nil(err)
+	assert.Len(registered, 1)
+
+	suite.assertError(suite.request("PUT", "/api/v1/services/", `{"app_id":"app","app_major_version":"v1",
+		"constraints":{"image_name":"registry.com/app"}}`), http.StatusConflict, "Elemento ya existe")
+	suite.assertError(suite.request("PUT", "/api/v1/services/", `{"app_id":`), http.StatusBadRequest, "Error de serializacion")
+	suite.assertError(suite.request("PUT", "/api/v1/services/", `{"app_id":"other","app_major_version":"v1",
+		"constraints":{"criteria":"cluster =="}}`), http.StatusBadRequest, "Expresion de criterios invalida")
+	suite.assertError(suite.request("PUT", "/api/v1/services/", `{"app_id":"other","app_major_version":"v1",
+		"constraints":{"image_name":"registry.com/app","checks":[{"type":"unknown"}]}}`), http.StatusBadRequest, "Chequeo invalido")
+	suite.assertError(suite.request("PUT", "/api/v1/services/", `{"app_id":"other","app_major_version":"v1",
+		"constraints":{}}`), http.StatusBadRequest, "Se requiere image_name o criteria")
+
+	// Los registros invalidos no se persisten
+	registered, err = suite.store.FindAll()
+	assert.Nil(err)
+	assert.Len(registered, 1)
+}
+
+func (suite *HandlersSuite) TestManagerAlreadyExist() {
+	params := service.Parameters{ID: "app", Version: "v1", Constraints: service.ConstraintsParams{ImageName: "registry.com/app"}}
+	_, err := suite.ctx.RegisterServiceManager(params)
+	suite.Require().Nil(err)
+
+	// El registro desde la API y la restauracion desde el store reportan el mismo conflicto
+	expected := &service.ManagerAlreadyExist{Service: "app", Version: "v1"}
+	_, err = suite.ctx.RegisterServiceManager(params)
+	suite.Equal(expected, err)
+
+	suite.ctx.serviceMux.Lock()
+	_, err = suite.ctx.registerServiceManager(params)
+	suite.ctx.serviceMux.Unlock()
+	suite.Equal(expected, err)
+}
+
+func (suite *HandlersSuite) TestPutServiceVersion() {
+	assert := assert.New(suite.T())
+	suite.putApp()
+
+	rec := suite.request("PUT", "/api/v1/services/app/versions", `{"version":"v2","image_name":"registry.com/app"}`)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var app types.Application
+	suite.decode(suite.request("GET", "/api/v1/services/app", ""), &app)
+	assert.Len(app.Versions, 2)
+	assert.Equal("v2", app.Versions[1].Version)
+
+	suite.assertError(suite.request("PUT", "/api/v1/services/app/versions", `{"image_name":"registry.com/app"}`),
+		http.StatusBadRequest, "Error de serializacion")
+	suite.assertError(suite.request("PUT", "/api/v1/services/other/versions", `{"version":"v2","image_name":"registry.com/app"}`),
+		http.StatusNotFound, "Servicio no existe")
+	suite.assertError(suite.request("PUT", "/api/v1/services/app/versions", `{"version":"v1","image_name":"registry.com/app"}`),
+		http.StatusConflict, "Elemento ya existe")
+}
+
+func (suite *HandlersSuite) TestRegistrationError() {
+	assert := assert.New(suite.T())
+
+	errs := []struct {
+		err      error
+		expected error
+	}{
+		{&service.ManagerAlreadyExist{Service: "app", Version: "v1"}, ElementAlreadyExists{}},
+		{&service.ImageNameRegexpError{Regexp: "("}, ImageNameRegexpError{}},
+		{&monitor.CriteriaExpressionError{Expression: "cluster =="}, InvalidCriteriaError{}},
+		{&service.MissingCriteria{Service: "app", Version: "v1"}, MissingCriteriaError{}},
+		{&service.InvalidCheckParams{Type: "unknown"}, InvalidCheckError{}},
+		{errors.New("otro"), UnknownError{}},
+	}
+	for _, e := range errs {
+		assert.IsType(e.expected, registrationError(e.err), e.err.Error())
+	}
+}
+
+func (suite *HandlersSuite) TestDeleteServiceVersion() {
+	assert := assert.New(suite.T())
+	suite.putApp()
+
+	rec := suite.request("DELETE", "/api/v1/services/app/versions/v1", "")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	registered, err := suite.store.FindAll()
+	assert.Nil(err)
+	assert.Len(registered, 0)
+
+	suite.assertError(suite.request("GET", "/api/v1/services/app", ""), http.StatusNotFound, "Servicio no existe")
+	suite.assertError(suite.request("DELETE", "/api/v1/services/app/versions/v1", ""), http.StatusNotFound, "Servicio no existe")
+}
+
+func (suite *HandlersSuite) TestGetServiceHistory() {
+	assert := assert.New(suite.T())
+
+	var history []types.HistoryEntry
+	suite.decode(suite.request("GET", "/api/v1/services/srv1/history", ""), &history)
+	if assert.Len(history, 2) {
+		assert.True(historyDate.Equal(*history[0].Date))
+		assert.Equal("wdc", history[0].Cluster)
+		assert.Equal("ServiceAdded", history[0].Status)
+		assert.Equal("v0.9", history[0].ImageTag)
+		assert.Equal("ServiceAdded", history[1].PreviousStatus)
+		assert.Equal("ServiceUpdated", history[1].Status)
+		assert.Equal([]types.ServiceChange{{Type: "ImageTagChanged", Previous: "v0.9", Current: "v1.0"}}, history[1].Changes)
+	}
+
+	filters := []struct {
+		query    string
+		expected []string
+	}{
+		{"from=" + historyDate.Add(time.Minute).Format(time.RFC3339), []string{"v1.0"}},
+		{"to=" + historyDate.Add(time.Minute).Format(time.RFC3339), []string{"v0.9"}},
+		{"from=" + historyDate.Format(time.RFC3339) + "&to=" + historyDate.Add(time.Hour).Format(time.RFC3339), []string{"v0.9", "v1.0"}},
+		{"from=" + historyDate.Add(2*time.Hour).Format(time.RFC3339), []string{}},
+	}
+	for _, filter := range filters {
+		var history []types.HistoryEntry
+		suite.decode(suite.request("GET", "/api/v1/services/srv1/history?"+filter.query, ""), &history)
+		tags := []string{}
+		for _, v := range history {
+			tags = append(tags, v.ImageTag)
+		}
+		assert.Equal(filter.expected, tags, filter.query)
+	}
+
+	suite.assertError(suite.request("GET", "/api/v1/services/srv2/history", ""), http.StatusNotFound, "Servicio no existe")
+	suite.assertError(suite.request("GET", "/api/v1/services/srv1/history?from=ayer", ""), http.StatusBadRequest, "Filtro invalido")
+	suite.assertError(suite.request("GET", "/api/v1/services/srv1/history?to=ayer", ""), http.StatusBadRequest, "Filtro invalido")
+}
+
+func (suite *HandlersSuite) TestGetNotifications() {
+	assert := assert.New(suite.T())
+
+	rec := suite.request("GET", "/api/v1/notifications", "")
+	assert.Equal(http.StatusOK, rec.Code)
+	var statuses []types.NotificationStatus
+	suite.decode(rec, &statuses)
+	assert.Len(statuses, 1)
+	assert.Equal("fake", statuses[0].ID)
+	assert.Equal(0, statuses[0].Total)
+}
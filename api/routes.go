@@ -1,7 +1,6 @@
 package api
 
 import (
-	"github.com/ch3lo/overlord/configuration"
 	"github.com/gorilla/mux"
 	"github.com/thoas/stats"
 )
@@ -16,17 +15,25 @@ var routesMap = map[string]map[string]serviceHandler{
 		"/": putService,
 		"/{service_id}/versions": putServiceVersionByServiceId,
 	},
+	"DELETE": {
+		"/{service_id}/versions/{version}": deleteServiceVersionByServiceId,
+	},
 }
 
-func routes(config *configuration.Configuration, sts *stats.Stats) *mux.Router {
-	ctx := newContext(config)
+func routes(ctx *appContext, sts *stats.Stats) *mux.Router {
 	router := mux.NewRouter()
 
-	router.Handle("/stats", &statsHandler{sts}).Methods("GET")
+	router.Handle("/stats", &statsHandler{sts, ctx}).Methods("GET")
+	router.Handle("/api/v1/events", errorHandler{getEvents, ctx}).Methods("GET")
+	router.Handle("/api/v1/notifications", errorHandler{getNotifications, ctx}).Methods("GET")
 
 	// API v1
 	v1Services := router.PathPrefix("/api/v1/services").Subrouter()
 
+	// Se registra antes que /{service_id}/{cluster} para que tenga prioridad, por eso
+	// setupClusters no permite un cluster con id history
+	v1Services.Handle("/{service_id}/"+historyPath, errorHandler{getServiceHistory, ctx}).Methods("GET")
+
 	for method, mappings := range routesMap {
 		for path, h := range mappings {
 			v1Services.Handle(path, errorHandler{h, ctx}).Methods(method)
@@ -1,12 +1,24 @@
 package api
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/ch3lo/overlord/configuration"
+	"github.com/ch3lo/overlord/logger"
 	"github.com/codegangsta/negroni"
 	"github.com/rs/cors"
 	"github.com/thoas/stats"
 )
 
+// shutdownTimeout tiempo maximo para cerrar las conexiones y entregar las notificaciones pendientes
+const shutdownTimeout = 30 * time.Second
+
 func Server(config *configuration.Configuration) {
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
@@ -19,12 +31,44 @@ func Server(config *configuration.Configuration) {
 
 	statsMiddleware := stats.New()
 
-	router := routes(config, statsMiddleware)
+	ctx := newContext(config)
+	router := routes(ctx, statsMiddleware)
 
 	n := negroni.Classic()
 	n.Use(corsMiddleware)
 	n.Use(statsMiddleware)
 	n.UseHandler(router)
 
-	n.Run(":8080")
+	// requestsCtx se cancela al detener el servidor para cerrar las conexiones de larga duracion (SSE)
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:        ":8080",
+		Handler:     n,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+
+	go func() {
+		logger.Instance().Infof("Escuchando en %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Instance().Fatalln(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	logger.Instance().Infof("Se recibio la señal %s, deteniendo overlord", sig)
+
+	// El servidor http y las notificaciones pendientes comparten el mismo plazo
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	cancelRequests()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Instance().Errorf("No se pudo detener el servidor http: %s", err.Error())
+	}
+
+	deadline, _ := shutdownCtx.Deadline()
+	ctx.Shutdown(time.Until(deadline))
+	logger.Instance().Infoln("Overlord detenido")
 }
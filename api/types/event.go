@@ -0,0 +1,35 @@
+package types
+
+import "time"
+
+// ServiceEvent representa un cambio detectado sobre un servicio de un cluster
+type ServiceEvent struct {
+	ServiceID        string          `json:"service_id"`
+	Cluster          string          `json:"cluster"`
+	PreviousStatus   string          `json:"previous_status,omitempty"`
+	Status           string          `json:"status"`
+	ImageName        string          `json:"image_name,omitempty"`
+	PreviousImageTag string          `json:"previous_image_tag,omitempty"`
+	ImageTag         string          `json:"image_tag,omitempty"`
+	Changes          []ServiceChange `json:"changes,omitempty"`
+	Flapping         bool            `json:"flapping,omitempty"`
+	Date             *time.Time      `json:"date,omitempty"`
+}
+
+// ServiceChange representa un cambio puntual detectado en un servicio
+type ServiceChange struct {
+	Type     string `json:"type"`
+	Instance string `json:"instance,omitempty"`
+	Previous string `json:"previous,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// HistoryEntry representa una transicion de estado registrada en el historial de un servicio
+type HistoryEntry struct {
+	Date           *time.Time      `json:"date"`
+	Cluster        string          `json:"cluster"`
+	PreviousStatus string          `json:"previous_status,omitempty"`
+	Status         string          `json:"status"`
+	ImageTag       string          `json:"image_tag,omitempty"`
+	Changes        []ServiceChange `json:"changes,omitempty"`
+}
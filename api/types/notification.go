@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// NotificationStatus representa las estadisticas de entrega de un notificador
+type NotificationStatus struct {
+	ID            string     `json:"id"`
+	Total         int        `json:"total"`
+	Success       int        `json:"success"`
+	Errors        int        `json:"errors"`
+	Failed        int        `json:"failed"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorDate *time.Time `json:"last_error_date,omitempty"`
+	LastSuccess   *time.Time `json:"last_success,omitempty"`
+}
@@ -8,6 +8,28 @@ type Instance struct {
 	Status       string     `json:"status,omitempty"`
 	Cluster      string     `json:"cluster,omitempty"`
 	Address      string     `json:"address"`
+	ImageName    string     `json:"image_name,omitempty"`
+	ImageTag     string     `json:"image_tag,omitempty"`
+	Healthy      bool       `json:"healthy"`
+}
+
+type CheckFailure struct {
+	Check     string `json:"check"`
+	Cluster   string `json:"cluster,omitempty"`
+	Host      string `json:"host,omitempty"`
+	Observed  int    `json:"observed"`
+	Threshold int    `json:"threshold"`
+}
+
+type CheckStatus struct {
+	Success          int            `json:"success"`
+	Failed           int            `json:"failed"`
+	ConsecutiveFails int            `json:"consecutive_fails"`
+	Threshold        int            `json:"threshold"`
+	LastCheck        *time.Time     `json:"last_check,omitempty"`
+	Alert            string         `json:"alert"`
+	AlertSince       *time.Time     `json:"alert_since,omitempty"`
+	Failures         []CheckFailure `json:"failures,omitempty"`
 }
 
 type AppMajorVersion struct {
@@ -15,8 +37,11 @@ type AppMajorVersion struct {
 	CreationDate *time.Time              `json:"creation_time,omitempty"`
 	ImageName    string                  `json:"image_name,omitempty"`
 	ImageTag     string                  `json:"image_tag,omitempty"`
+	Criteria     string                  `json:"criteria,omitempty"`
+	Checks       []CheckMapper           `json:"checks,omitempty"`
 	Instances    []Instance              `json:"instances,omitempty"`
 	ClusterCheck map[string]ClusterCheck `json:"cluster_check"`
+	CheckStatus  *CheckStatus            `json:"check_status,omitempty"`
 }
 
 type Application struct {
@@ -32,6 +57,15 @@ type ClusterCheck struct {
 type ConstraintMapper struct {
 	ImageName    string                  `json:"image_name,omitempty", valid:"alphanum"`
 	ClusterCheck map[string]ClusterCheck `json:"cluster_check"`
+	Criteria     string                  `json:"criteria,omitempty"`
+	Checks       []CheckMapper           `json:"checks,omitempty"`
+}
+
+type CheckMapper struct {
+	Type                string         `json:"type"`
+	MinHosts            int            `json:"min_hosts,omitempty"`
+	MinHostsPerCluster  map[string]int `json:"min_hosts_per_cluster,omitempty"`
+	MaxInstancesPerHost int            `json:"max_instances_per_host,omitempty"`
 }
 
 type AppRequest struct {
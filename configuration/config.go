@@ -28,17 +28,64 @@ type Cluster struct {
 	Scheduler Scheduler `yaml:"scheduler"`
 }
 
+// ClusterUpdater permite sobreescribir para un cluster la configuracion de consulta del Updater
+type ClusterUpdater struct {
+	Interval time.Duration `yaml:"interval,omitempty"` // cada cuanto se consulta el scheduler del cluster
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // tiempo maximo de espera de la respuesta del scheduler
+}
+
 type Updater struct {
-	Interval time.Duration `yaml:"interval,omitempty"`
+	Interval                time.Duration             `yaml:"interval,omitempty"`
+	Timeout                 time.Duration             `yaml:"timeout,omitempty"`
+	Clusters                map[string]ClusterUpdater `yaml:"clusters,omitempty"`
+	HistorySize             int                       `yaml:"historySize,omitempty"`             // cantidad de transiciones que se guardan por servicio
+	MissedPollsBeforeRemove int                       `yaml:"missedPollsBeforeRemove,omitempty"` // consultas seguidas sin aparecer antes de confirmar la remocion
+	RemoveGracePeriod       time.Duration             `yaml:"removeGracePeriod,omitempty"`       // tiempo minimo sin aparecer antes de confirmar la remocion
+	FlapWindow              time.Duration             `yaml:"flapWindow,omitempty"`              // ventana en que se cuentan las desapariciones de un servicio
+	FlapThreshold           int                       `yaml:"flapThreshold,omitempty"`           // desapariciones dentro de la ventana para marcarlo intermitente
+	RemovedRetention        time.Duration             `yaml:"removedRetention,omitempty"`        // tiempo que se mantiene en memoria un servicio removido
+	MaxRemoved              int                       `yaml:"maxRemoved,omitempty"`              // cantidad maxima de servicios removidos en memoria
+	SweepInterval           time.Duration             `yaml:"sweepInterval,omitempty"`           // cada cuanto se eliminan los servicios removidos
+	SnapshotFile            string                    `yaml:"snapshotFile,omitempty"`            // archivo donde se respalda el estado de los servicios. Vacio lo deshabilita
+	SnapshotInterval        time.Duration             `yaml:"snapshotInterval,omitempty"`        // cada cuanto se respalda el estado de los servicios
 }
 
 type Check struct {
-	Interval  time.Duration `yaml:"interval,omitempty"`
-	Threshold int           `yaml:"threshold,omitempty"`
+	Interval       time.Duration `yaml:"interval,omitempty"`
+	Threshold      int           `yaml:"threshold,omitempty"`
+	RepeatInterval time.Duration `yaml:"repeatInterval,omitempty"` // cada cuanto se vuelve a notificar una alerta activa, 0 no la repite
+}
+
+// Checker configura un chequeo de la cadena de chequeos de los managers
+// Los tipos disponibles son min-instances, unique-host (minHosts, minHostsPerCluster),
+// multi-tags y max-instances-per-host (maxInstancesPerHost)
+type Checker struct {
+	Type                string         `yaml:"type"`
+	MinHosts            int            `yaml:"minHosts,omitempty"`
+	MinHostsPerCluster  map[string]int `yaml:"minHostsPerCluster,omitempty"`
+	MaxInstancesPerHost int            `yaml:"maxInstancesPerHost,omitempty"`
 }
 
 type Manager struct {
-	Check Check `yaml:"check,omitempty"`
+	Check  Check     `yaml:"check,omitempty"`
+	Checks []Checker `yaml:"checks,omitempty"` // cadena de chequeos por defecto, en orden
+}
+
+// Service declara una version de un servicio a monitorear desde la configuracion
+// Criteria es una expresion de criterios, por ejemplo: cluster in (wdc,dal) && status != removed
+type Service struct {
+	ID                     string         `yaml:"id"`
+	Version                string         `yaml:"version"`
+	ImageName              string         `yaml:"imageName,omitempty"`
+	Criteria               string         `yaml:"criteria,omitempty"`
+	MinInstancesPerCluster map[string]int `yaml:"minInstancesPerCluster,omitempty"`
+	Checks                 []Checker      `yaml:"checks,omitempty"`
+}
+
+// Store configura donde se persisten los servicios registrados
+type Store struct {
+	StoreType string     `yaml:"type,omitempty"`
+	Config    Parameters `yaml:"config,omitempty"`
 }
 
 type Configuration struct {
@@ -46,6 +93,8 @@ type Configuration struct {
 	Manager      Manager            `yaml:"manager,omitempty"`
 	Clusters     map[string]Cluster `yaml:"cluster"`
 	Notification Notification       `yaml:"notification,omitempty"`
+	Store        Store              `yaml:"store,omitempty"`
+	Services     []Service          `yaml:"services,omitempty"`
 }
 
 type Notification struct {
@@ -53,6 +102,19 @@ type Notification struct {
 	WaitOnError      time.Duration                   `yaml:"waitOnError,omitempty"`
 	WaitAfterAttemts time.Duration                   `yaml:"waitAfterAttemts,omitempty"`
 	Providers        map[string]NotificationProvider `yaml:"providers,omitempty"`
+	Routes           []NotificationRoute             `yaml:"routes,omitempty"`
+}
+
+// NotificationRoute envia las alertas que cumplen todas sus condiciones a los notificadores Providers
+// App es una expresion regular sobre el id de la app. Las condiciones vacias no filtran.
+// Las rutas se evaluan en orden y se detienen en la primera que se cumple salvo que tenga continue
+type NotificationRoute struct {
+	App        string   `yaml:"app,omitempty"`
+	Clusters   []string `yaml:"clusters,omitempty"`
+	Severities []string `yaml:"severities,omitempty"`
+	Checks     []string `yaml:"checks,omitempty"`
+	Providers  []string `yaml:"providers"`
+	Continue   bool     `yaml:"continue,omitempty"`
 }
 
 type NotificationProvider struct {
@@ -14,11 +14,34 @@ import (
 var configStruct = Configuration{
 	Updater: Updater{
 		Interval: 10 * time.Second,
+		Timeout:  20 * time.Second,
+		Clusters: map[string]ClusterUpdater{
+			"dal": {
+				Interval: 30 * time.Second,
+				Timeout:  time.Minute,
+			},
+		},
+		HistorySize:             100,
+		MissedPollsBeforeRemove: 3,
+		RemoveGracePeriod:       30 * time.Second,
+		FlapWindow:              5 * time.Minute,
+		FlapThreshold:           4,
+		RemovedRetention:        2 * time.Hour,
+		MaxRemoved:              500,
+		SweepInterval:           5 * time.Minute,
+		SnapshotFile:            "/var/lib/overlord/updater.json",
+		SnapshotInterval:        time.Minute,
 	},
 	Manager: Manager{
 		Check: Check{
-			Interval:  30 * time.Second,
-			Threshold: 4,
+			Interval:       30 * time.Second,
+			Threshold:      4,
+			RepeatInterval: time.Hour,
+		},
+		Checks: []Checker{
+			{Type: "min-instances"},
+			{Type: "unique-host", MinHosts: 3, MinHostsPerCluster: map[string]int{"sjc": 1}},
+			{Type: "max-instances-per-host", MaxInstancesPerHost: 2},
 		},
 	},
 	Clusters: map[string]Cluster{
@@ -92,6 +115,35 @@ var configStruct = Configuration{
 				},
 			},
 		},
+		Routes: []NotificationRoute{
+			{
+				App:        "^payments",
+				Severities: []string{"critical", "info"},
+				Providers:  []string{"rundeck-id"},
+				Continue:   true,
+			},
+			{
+				Clusters:  []string{"wdc"},
+				Checks:    []string{"min-instances"},
+				Providers: []string{"email-id", "email-id2"},
+			},
+		},
+	},
+	Store: Store{
+		StoreType: "file",
+		Config: Parameters{
+			"path": "/var/lib/overlord/services.json",
+		},
+	},
+	Services: []Service{
+		{
+			ID:                     "app",
+			Version:                "v1",
+			ImageName:              "registry/app",
+			Criteria:               "cluster in (wdc,dal) && status != removed",
+			MinInstancesPerCluster: map[string]int{"wdc": 2},
+			Checks:                 []Checker{{Type: "multi-tags"}},
+		},
 	},
 }
 
@@ -99,10 +151,34 @@ var configStruct = Configuration{
 var configYaml = `
 updater:
   interval: 10s
+  timeout: 20s
+  clusters:
+    dal:
+      interval: 30s
+      timeout: 1m
+  historySize: 100
+  missedPollsBeforeRemove: 3
+  removeGracePeriod: 30s
+  flapWindow: 5m
+  flapThreshold: 4
+  removedRetention: 2h
+  maxRemoved: 500
+  sweepInterval: 5m
+  snapshotFile: /var/lib/overlord/updater.json
+  snapshotInterval: 1m
 manager:
   check:
     interval: 30s
     threshold: 4
+    repeatInterval: 1h
+  checks:
+    - type: min-instances
+    - type: unique-host
+      minHosts: 3
+      minHostsPerCluster:
+        sjc: 1
+    - type: max-instances-per-host
+      maxInstancesPerHost: 2
 cluster:
   dal:
     scheduler:
@@ -157,6 +233,27 @@ notification:
         endpoint: http://rundeck.com
         token: qwerty123
         job: asd321
+  routes:
+    - app: ^payments
+      severities: [critical, info]
+      providers: [rundeck-id]
+      continue: true
+    - clusters: [wdc]
+      checks: [min-instances]
+      providers: [email-id, email-id2]
+store:
+  type: file
+  config:
+    path: /var/lib/overlord/services.json
+services:
+  - id: app
+    version: v1
+    imageName: registry/app
+    criteria: cluster in (wdc,dal) && status != removed
+    minInstancesPerCluster:
+      wdc: 2
+    checks:
+      - type: multi-tags
 `
 
 func Test(t *testing.T) {
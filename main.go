@@ -5,6 +5,7 @@ import (
 	//Necesarios para que funcione el init()
 	_ "github.com/ch3lo/overlord/notification/email"
 	_ "github.com/ch3lo/overlord/notification/http"
+	_ "github.com/ch3lo/overlord/store/file"
 	_ "github.com/latam-airlines/mesos-framework-factory/marathon"
 	_ "github.com/latam-airlines/mesos-framework-factory/swarm"
 )
@@ -1,6 +1,8 @@
 package report
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/ch3lo/overlord/logger"
@@ -9,15 +11,20 @@ import (
 )
 
 type Broadcast interface {
-	Broadcast(data []byte)
+	Broadcast(alert *notification.Alert)
 	Register(n notification.Notification) error
+	Stop(timeout time.Duration) bool
+	Status() []WorkerStatus
 }
 
 type Broadcaster struct {
 	attemptsOnError  int
 	waitOnError      time.Duration
 	waitAfterAttemts time.Duration
-	workers          map[string]notification.Notification
+	workers          map[string]*BroadcastWorker
+	routes           []Route
+	inFlight         sync.WaitGroup
+	quitChan         chan bool
 }
 
 func NewBroadcaster(attemptsOnError int, waitOnError time.Duration, waitAfterAttemts time.Duration) *Broadcaster {
@@ -37,7 +44,8 @@ func NewBroadcaster(attemptsOnError int, waitOnError time.Duration, waitAfterAtt
 		attemptsOnError:  attemptsOnError,
 		waitOnError:      waitOnError,
 		waitAfterAttemts: waitAfterAttemts,
-		workers:          make(map[string]notification.Notification),
+		workers:          make(map[string]*BroadcastWorker),
+		quitChan:         make(chan bool),
 	}
 
 	return b
@@ -47,30 +55,134 @@ func (b *Broadcaster) Register(n notification.Notification) error {
 	if _, ok := b.workers[n.ID()]; ok {
 		return &BroadcastWorkerAlreadyExist{Name: n.ID()}
 	}
-	b.workers[n.ID()] = BroadcastWorker{
+	b.workers[n.ID()] = &BroadcastWorker{
 		attemptsOnError:  b.attemptsOnError,
 		waitOnError:      b.waitOnError,
 		waitAfterAttemts: b.waitAfterAttemts,
 		notification:     n,
+		inFlight:         &b.inFlight,
+		quitChan:         b.quitChan,
 	}
 	return nil
 }
 
-func (b *Broadcaster) Broadcast(data []byte) {
+// SetRoutes configura las rutas que deciden a que notificadores se envia cada alerta
+// Sin rutas las alertas se envian a todos los notificadores
+func (b *Broadcaster) SetRoutes(routes []Route) error {
+	for i, r := range routes {
+		if len(r.Providers) == 0 {
+			return &RouteWithoutProviders{Index: i}
+		}
+		for _, p := range r.Providers {
+			if _, ok := b.workers[p]; !ok {
+				return &BroadcastWorkerNotFound{Name: p}
+			}
+		}
+	}
+	b.routes = routes
+	return nil
+}
+
+// recipients retorna los notificadores a los que se debe enviar la alerta segun las rutas
+func (b *Broadcaster) recipients(alert *notification.Alert) []*BroadcastWorker {
+	if len(b.routes) == 0 {
+		recipients := make([]*BroadcastWorker, 0, len(b.workers))
+		for _, v := range b.workers {
+			recipients = append(recipients, v)
+		}
+		return recipients
+	}
+
+	var recipients []*BroadcastWorker
+	selected := make(map[string]bool)
+	for _, r := range b.routes {
+		if !r.Match(alert) {
+			continue
+		}
+
+		for _, p := range r.Providers {
+			if !selected[p] {
+				selected[p] = true
+				recipients = append(recipients, b.workers[p])
+			}
+		}
+
+		if !r.Continue {
+			break
+		}
+	}
+	return recipients
+}
+
+func (b *Broadcaster) Broadcast(alert *notification.Alert) {
+	recipients := b.recipients(alert)
+	if len(recipients) == 0 {
+		logger.Instance().Warnf("Ninguna ruta de notificacion acepta la alerta %s del manager %s", alert.Status, alert.ManagerID)
+		return
+	}
+
+	for _, v := range recipients {
+		v.Notify(alert)
+	}
+}
+
+// Stop espera hasta timeout a que se entreguen las notificaciones en curso
+// Luego detiene los reintentos pendientes. Retorna true si se entregaron todas
+func (b *Broadcaster) Stop(timeout time.Duration) bool {
+	done := make(chan bool)
+	go func() {
+		b.inFlight.Wait()
+		close(done)
+	}()
+
+	flushed := true
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Instance().Warnf("No se alcanzaron a entregar todas las notificaciones en %s", timeout)
+		flushed = false
+	}
+
+	close(b.quitChan)
+	return flushed
+}
+
+// Status retorna las estadisticas de entrega de cada worker ordenadas por id
+func (b *Broadcaster) Status() []WorkerStatus {
+	statuses := make([]WorkerStatus, 0, len(b.workers))
 	for _, v := range b.workers {
-		v.Notify(data)
+		statuses = append(statuses, v.Status())
 	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
 }
 
 func (b *Broadcaster) Send() {
 
 }
 
-type boradcastStatus struct {
-	total   int
-	success int
-	errors  int
-	fail    int
+// WorkerStatus es una copia de solo lectura de las estadisticas de entrega de un BroadcastWorker
+// Errors cuenta los intentos fallidos y Failed las notificaciones que agotaron sus reintentos
+type WorkerStatus struct {
+	ID            string
+	Total         int
+	Success       int
+	Errors        int
+	Failed        int
+	LastError     string
+	LastErrorDate time.Time
+	LastSuccess   time.Time
+}
+
+type broadcastStatus struct {
+	mux           sync.Mutex
+	total         int
+	success       int
+	errors        int
+	fail          int
+	lastError     string
+	lastErrorDate time.Time
+	lastSuccess   time.Time
 }
 
 type BroadcastWorker struct {
@@ -78,18 +190,44 @@ type BroadcastWorker struct {
 	waitOnError      time.Duration
 	waitAfterAttemts time.Duration
 	notification     notification.Notification
-	status           boradcastStatus
+	status           broadcastStatus
+	inFlight         *sync.WaitGroup
 	quitChan         chan bool
 }
 
-func (w BroadcastWorker) ID() string {
+func (w *BroadcastWorker) ID() string {
 	return w.notification.ID()
 }
 
-func (w BroadcastWorker) Notify(data []byte) error {
-	w.status.total++
+// Status retorna las estadisticas de entrega del worker
+func (w *BroadcastWorker) Status() WorkerStatus {
+	w.status.mux.Lock()
+	defer w.status.mux.Unlock()
+
+	return WorkerStatus{
+		ID:            w.ID(),
+		Total:         w.status.total,
+		Success:       w.status.success,
+		Errors:        w.status.errors,
+		Failed:        w.status.fail,
+		LastError:     w.status.lastError,
+		LastErrorDate: w.status.lastErrorDate,
+		LastSuccess:   w.status.lastSuccess,
+	}
+}
+
+func (w *BroadcastWorker) updateStatus(update func(status *broadcastStatus)) {
+	w.status.mux.Lock()
+	defer w.status.mux.Unlock()
+	update(&w.status)
+}
+
+func (w *BroadcastWorker) Notify(alert *notification.Alert) error {
+	w.updateStatus(func(status *broadcastStatus) { status.total++ })
 
+	w.inFlight.Add(1)
 	go func() {
+		defer w.inFlight.Done()
 		for {
 			select {
 			case <-w.quitChan:
@@ -98,26 +236,44 @@ func (w BroadcastWorker) Notify(data []byte) error {
 
 			default:
 				err := try.Do(func(attempt int) (bool, error) {
-					err := w.notification.Notify(data)
+					err := w.notification.Notify(alert)
 					if err == nil {
 						return false, nil
 					}
-					w.status.errors++
+					w.updateStatus(func(status *broadcastStatus) {
+						status.errors++
+						status.lastError = err.Error()
+						status.lastErrorDate = time.Now()
+					})
 					retry := attempt < w.attemptsOnError
-					if retry {
-						time.Sleep(w.waitOnError)
+					if retry && !w.wait(w.waitOnError) {
+						return false, err
 					}
 					return retry, err
 				})
 				if err == nil {
-					w.status.success++
+					w.updateStatus(func(status *broadcastStatus) {
+						status.success++
+						status.lastSuccess = time.Now()
+					})
 					return
 				}
-				w.status.fail++
-				logger.Instance().Warnf("No se pudo notificar, se esperara un tiempo: %s", err.Error())
-				time.Sleep(w.waitAfterAttemts)
+				w.updateStatus(func(status *broadcastStatus) { status.fail++ })
+				logger.Instance().WithField("notification", w.ID()).Warnf("No se pudo notificar, se esperara un tiempo: %s", err.Error())
+				w.wait(w.waitAfterAttemts)
 			}
 		}
 	}()
 	return nil
 }
+
+// wait espera d o hasta que se detenga el worker
+// Retorna false si el worker se detuvo durante la espera
+func (w *BroadcastWorker) wait(d time.Duration) bool {
+	select {
+	case <-w.quitChan:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
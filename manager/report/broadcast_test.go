@@ -0,0 +1,199 @@
+package report
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/notification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBroadcast(t *testing.T) {
+	suite.Run(t, new(BroadcastSuite))
+}
+
+type recorder struct {
+	mux      sync.Mutex
+	notified []string
+}
+
+func (r *recorder) add(id string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.notified = append(r.notified, id)
+}
+
+func (r *recorder) sorted() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	notified := append([]string{}, r.notified...)
+	sort.Strings(notified)
+	return notified
+}
+
+type fakeNotification struct {
+	id       string
+	recorder *recorder
+}
+
+func (n *fakeNotification) ID() string { return n.id }
+
+func (n *fakeNotification) Notify(alert *notification.Alert) error {
+	n.recorder.add(n.id)
+	return nil
+}
+
+type BroadcastSuite struct {
+	suite.Suite
+	recorder    *recorder
+	broadcaster *Broadcaster
+	alert       *notification.Alert
+}
+
+func (suite *BroadcastSuite) SetupTest() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+	suite.recorder = &recorder{}
+	suite.broadcaster = NewBroadcaster(1, time.Millisecond, time.Millisecond)
+	for _, id := range []string{"payments-http", "ops-email", "dal-email"} {
+		suite.broadcaster.Register(&fakeNotification{id: id, recorder: suite.recorder})
+	}
+
+	suite.alert = &notification.Alert{
+		App:      "payments-api",
+		Severity: notification.SeverityCritical,
+		Checks:   []string{"min-instances"},
+		Failures: []notification.AlertFailure{{Check: "min-instances", Cluster: "wdc"}},
+		Clusters: map[string]notification.ClusterCount{"wdc": {}, "dal": {}},
+	}
+}
+
+func (suite *BroadcastSuite) broadcast(alert *notification.Alert) []string {
+	suite.broadcaster.Broadcast(alert)
+	suite.broadcaster.Stop(time.Second)
+	return suite.recorder.sorted()
+}
+
+func (suite *BroadcastSuite) TestWithoutRoutes() {
+	assert.Equal(suite.T(), []string{"dal-email", "ops-email", "payments-http"}, suite.broadcast(suite.alert))
+}
+
+func (suite *BroadcastSuite) routes() []Route {
+	return []Route{
+		{App: regexp.MustCompile("^payments"), Providers: []string{"payments-http"}},
+		{Clusters: []string{"dal"}, Providers: []string{"dal-email"}, Continue: true},
+		{Providers: []string{"ops-email"}},
+	}
+}
+
+func (suite *BroadcastSuite) TestFirstMatch() {
+	assert.Nil(suite.T(), suite.broadcaster.SetRoutes(suite.routes()))
+	assert.Equal(suite.T(), []string{"payments-http"}, suite.broadcast(suite.alert))
+}
+
+func (suite *BroadcastSuite) TestContinue() {
+	assert.Nil(suite.T(), suite.broadcaster.SetRoutes(suite.routes()))
+	suite.alert.App = "orders"
+	suite.alert.Failures[0].Cluster = "dal"
+	assert.Equal(suite.T(), []string{"dal-email", "ops-email"}, suite.broadcast(suite.alert))
+}
+
+func (suite *BroadcastSuite) TestWithoutMatch() {
+	assert.Nil(suite.T(), suite.broadcaster.SetRoutes([]Route{{Severities: []string{"info"}, Providers: []string{"ops-email"}}}))
+	assert.Len(suite.T(), suite.broadcast(suite.alert), 0)
+}
+
+func (suite *BroadcastSuite) TestUnknownProvider() {
+	err := suite.broadcaster.SetRoutes([]Route{{Providers: []string{"unknown"}}})
+	assert.IsType(suite.T(), &BroadcastWorkerNotFound{}, err)
+}
+
+func (suite *BroadcastSuite) TestRouteWithoutProviders() {
+	err := suite.broadcaster.SetRoutes([]Route{{Severities: []string{"critical"}}})
+	assert.IsType(suite.T(), &RouteWithoutProviders{}, err)
+}
+
+func (suite *BroadcastSuite) TestRouteMatch() {
+	assert := assert.New(suite.T())
+	assert.True((&Route{Checks: []string{"unique-host", "min-instances"}}).Match(suite.alert))
+	assert.False((&Route{Checks: []string{"multi-tags"}}).Match(suite.alert))
+	assert.True((&Route{Severities: []string{"critical"}}).Match(suite.alert))
+	assert.False((&Route{Clusters: []string{"dal"}}).Match(suite.alert))
+
+	suite.alert.Failures = nil
+	assert.True((&Route{Clusters: []string{"dal"}}).Match(suite.alert))
+
+	suite.alert.Severity = notification.SeverityInfo
+	suite.alert.RouteSeverity = notification.SeverityCritical
+	suite.alert.RouteClusters = []string{"wdc"}
+	assert.True((&Route{Severities: []string{"critical"}, Clusters: []string{"wdc"}}).Match(suite.alert))
+	assert.False((&Route{Clusters: []string{"dal"}}).Match(suite.alert))
+}
+
+type failingNotification struct {
+	mux      sync.Mutex
+	failures int
+}
+
+func (n *failingNotification) ID() string { return "failing" }
+
+func (n *failingNotification) Notify(alert *notification.Alert) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (suite *BroadcastSuite) TestStatus() {
+	assert := assert.New(suite.T())
+	b := NewBroadcaster(2, time.Millisecond, time.Millisecond)
+	b.Register(&failingNotification{failures: 3})
+	b.Register(&fakeNotification{id: "ok", recorder: suite.recorder})
+
+	b.Broadcast(suite.alert)
+	b.Broadcast(suite.alert)
+	assert.True(b.Stop(time.Second))
+
+	statuses := b.Status()
+	assert.Len(statuses, 2)
+
+	failing := statuses[0]
+	assert.Equal("failing", failing.ID)
+	assert.Equal(2, failing.Total)
+	assert.Equal(2, failing.Success)
+	assert.Equal(3, failing.Errors)
+	assert.Equal(1, failing.Failed)
+	assert.Equal("connection refused", failing.LastError)
+	assert.False(failing.LastErrorDate.IsZero())
+	assert.False(failing.LastSuccess.IsZero())
+
+	ok := statuses[1]
+	assert.Equal(WorkerStatus{ID: "ok", Total: 2, Success: 2, LastSuccess: ok.LastSuccess}, ok)
+}
+
+func (suite *BroadcastSuite) TestStopDuringRetry() {
+	b := NewBroadcaster(5, time.Hour, time.Hour)
+	b.Register(&failingNotification{failures: 10})
+	b.Broadcast(suite.alert)
+
+	assert.False(suite.T(), b.Stop(10*time.Millisecond))
+
+	done := make(chan bool)
+	go func() {
+		b.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		suite.T().Error("La notificacion no se detuvo durante la espera entre reintentos")
+	}
+}
@@ -10,3 +10,21 @@ type BroadcastWorkerAlreadyExist struct {
 func (err BroadcastWorkerAlreadyExist) Error() string {
 	return fmt.Sprintf("El broadcast worker ya existe: %s", err.Name)
 }
+
+// BroadcastWorkerNotFound sucede cuando una ruta de notificacion hace referencia a un broadcast worker que no existe
+type BroadcastWorkerNotFound struct {
+	Name string
+}
+
+func (err BroadcastWorkerNotFound) Error() string {
+	return fmt.Sprintf("El broadcast worker no existe: %s", err.Name)
+}
+
+// RouteWithoutProviders sucede cuando una ruta de notificacion no tiene notificadores
+type RouteWithoutProviders struct {
+	Index int
+}
+
+func (err RouteWithoutProviders) Error() string {
+	return fmt.Sprintf("La ruta de notificacion %d no tiene notificadores", err.Index)
+}
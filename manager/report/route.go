@@ -0,0 +1,81 @@
+package report
+
+import (
+	"regexp"
+
+	"github.com/ch3lo/overlord/notification"
+)
+
+// Route envia las alertas que cumplen todas sus condiciones a los notificadores Providers
+// Una condicion vacia no filtra, por lo que una ruta sin condiciones recibe todas las alertas.
+// Si Continue es false no se evaluan las rutas siguientes cuando la ruta se cumple
+type Route struct {
+	App        *regexp.Regexp
+	Clusters   []string
+	Severities []string
+	Checks     []string
+	Providers  []string
+	Continue   bool
+}
+
+// Match indica si la alerta cumple con las condiciones de la ruta
+func (r *Route) Match(alert *notification.Alert) bool {
+	if r.App != nil && !r.App.MatchString(alert.App) {
+		return false
+	}
+
+	if len(r.Clusters) > 0 && !containsAny(r.Clusters, alertClusters(alert)) {
+		return false
+	}
+
+	if len(r.Severities) > 0 && !containsAny(r.Severities, []string{string(alertSeverity(alert))}) {
+		return false
+	}
+
+	if len(r.Checks) > 0 && !containsAny(r.Checks, alert.Checks) {
+		return false
+	}
+
+	return true
+}
+
+// alertSeverity retorna la severidad con que se enruta la alerta
+func alertSeverity(alert *notification.Alert) notification.Severity {
+	if alert.RouteSeverity != "" {
+		return alert.RouteSeverity
+	}
+	return alert.Severity
+}
+
+// alertClusters retorna los clusters con que se enruta la alerta, los de sus fallas o, si no
+// tiene, los clusters donde se ejecuta el servicio
+func alertClusters(alert *notification.Alert) []string {
+	if len(alert.RouteClusters) > 0 {
+		return alert.RouteClusters
+	}
+
+	var clusters []string
+	for _, f := range alert.Failures {
+		if f.Cluster != "" {
+			clusters = append(clusters, f.Cluster)
+		}
+	}
+
+	if len(clusters) == 0 {
+		for k := range alert.Clusters {
+			clusters = append(clusters, k)
+		}
+	}
+	return clusters
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, v := range values {
+		for _, c := range candidates {
+			if v == c {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package service
+
+import "time"
+
+// AlertStatus es el estado de la alerta de los chequeos de un Manager
+type AlertStatus int
+
+const (
+	// AlertInactive los chequeos no superan el threshold de fallas consecutivas
+	AlertInactive AlertStatus = iota
+	// AlertFiring los chequeos superaron el threshold de fallas consecutivas
+	AlertFiring
+	// AlertRenotify la alerta sigue activa y se volvio a notificar tras el intervalo de repeticion
+	AlertRenotify
+	// AlertResolved los chequeos se recuperaron despues de una alerta
+	AlertResolved
+)
+
+var alertStatuses = []string{
+	"Inactive",
+	"Firing",
+	"Renotify",
+	"Resolved",
+}
+
+func (s AlertStatus) String() string {
+	if s < 0 || int(s) >= len(alertStatuses) {
+		return ""
+	}
+	return alertStatuses[s]
+}
+
+// alertState mantiene la alerta de un Manager entre chequeos
+// repeatInterval es cada cuanto se vuelve a notificar una alerta activa, si es 0 no se repite
+type alertState struct {
+	status         AlertStatus
+	since          time.Time
+	lastNotified   time.Time
+	repeatInterval time.Duration
+}
+
+// firing indica si la alerta esta activa
+func (a *alertState) firing() bool {
+	return a.status == AlertFiring || a.status == AlertRenotify
+}
+
+// transition actualiza la alerta con el resultado de un chequeo
+// Una alerta resuelta vuelve a estar inactiva en el chequeo siguiente
+// Retorna el nuevo estado y si se debe notificar
+func (a *alertState) transition(ok bool, consecutiveFails int, threshold int, now time.Time) (AlertStatus, bool) {
+	if a.status == AlertResolved {
+		a.status = AlertInactive
+		a.since = now
+	}
+
+	switch {
+	case a.firing() && ok:
+		a.status = AlertResolved
+		a.since = now
+		a.lastNotified = now
+		return a.status, true
+	case a.firing():
+		if a.repeatInterval > 0 && now.Sub(a.lastNotified) >= a.repeatInterval {
+			a.status = AlertRenotify
+			a.lastNotified = now
+			return a.status, true
+		}
+		return a.status, false
+	case !ok && consecutiveFails >= threshold:
+		a.status = AlertFiring
+		a.since = now
+		a.lastNotified = now
+		return a.status, true
+	}
+	return a.status, false
+}
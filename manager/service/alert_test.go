@@ -0,0 +1,215 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/manager/report"
+	"github.com/ch3lo/overlord/notification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestAlert(t *testing.T) {
+	suite.Run(t, new(AlertSuite))
+}
+
+type AlertSuite struct {
+	suite.Suite
+	date time.Time
+}
+
+func (suite *AlertSuite) SetupTest() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+	suite.date, _ = time.Parse(time.RFC3339, "2012-11-01T22:08:41+00:00")
+}
+
+func (suite *AlertSuite) at(minutes int) time.Time {
+	return suite.date.Add(time.Duration(minutes) * time.Minute)
+}
+
+func (suite *AlertSuite) assertTransition(a *alertState, ok bool, fails int, minutes int, expected AlertStatus, notify bool) {
+	status, n := a.transition(ok, fails, 3, suite.at(minutes))
+	assert.Equal(suite.T(), expected, status)
+	assert.Equal(suite.T(), notify, n)
+}
+
+func (suite *AlertSuite) TestTransitions() {
+	a := &alertState{repeatInterval: 30 * time.Minute}
+	suite.assertTransition(a, false, 1, 0, AlertInactive, false)
+	suite.assertTransition(a, false, 2, 1, AlertInactive, false)
+	suite.assertTransition(a, false, 3, 2, AlertFiring, true)
+	suite.assertTransition(a, false, 4, 3, AlertFiring, false)
+	suite.assertTransition(a, false, 5, 32, AlertRenotify, true)
+	suite.assertTransition(a, false, 6, 33, AlertRenotify, false)
+	suite.assertTransition(a, true, 0, 34, AlertResolved, true)
+	suite.assertTransition(a, true, 0, 35, AlertInactive, false)
+	assert.Equal(suite.T(), suite.at(35), a.since)
+	suite.assertTransition(a, false, 1, 36, AlertInactive, false)
+	suite.assertTransition(a, false, 3, 37, AlertFiring, true)
+	assert.Equal(suite.T(), suite.at(37), a.since)
+}
+
+func (suite *AlertSuite) TestFiringAfterResolved() {
+	a := &alertState{}
+	suite.assertTransition(a, false, 3, 0, AlertFiring, true)
+	suite.assertTransition(a, true, 0, 1, AlertResolved, true)
+	suite.assertTransition(a, false, 3, 2, AlertFiring, true)
+}
+
+func (suite *AlertSuite) TestWithoutRepeat() {
+	a := &alertState{}
+	suite.assertTransition(a, false, 3, 0, AlertFiring, true)
+	suite.assertTransition(a, false, 4, 600, AlertFiring, false)
+}
+
+func (suite *AlertSuite) TestStatusString() {
+	assert.Equal(suite.T(), "Firing", AlertFiring.String())
+	assert.Equal(suite.T(), "Resolved", AlertResolved.String())
+	assert.Equal(suite.T(), "", AlertStatus(10).String())
+}
+
+type fakeBroadcaster struct {
+	alerts []*notification.Alert
+}
+
+func (b *fakeBroadcaster) Broadcast(alert *notification.Alert) {
+	b.alerts = append(b.alerts, alert)
+}
+
+func (b *fakeBroadcaster) Register(n notification.Notification) error { return nil }
+
+func (b *fakeBroadcaster) Stop(timeout time.Duration) bool { return true }
+
+func (b *fakeBroadcaster) Status() []report.WorkerStatus { return nil }
+
+func (suite *AlertSuite) TestManagerCheck() {
+	assert := assert.New(suite.T())
+	broadcaster := &fakeBroadcaster{}
+	m := &Manager{
+		id:          "app#v1",
+		Version:     "v1",
+		threshold:   2,
+		broadcaster: broadcaster,
+		checkStatus: &MinInstancesCheck{MinInstancesPerCluster: map[string]int{"wdc": 1}},
+		App:         NewAppMajor(Parameters{ID: "app", Version: "v1"}),
+	}
+
+	m.App.Instances["instance1"] = &Instance{ID: "instance1", ClusterID: "wdc", Healthy: false}
+	m.check()
+	m.check()
+	assert.Len(broadcaster.alerts, 1)
+	firing := broadcaster.alerts[0]
+	assert.Equal("app#v1", firing.ManagerID)
+	assert.Equal("app", firing.App)
+	assert.Equal("v1", firing.Version)
+	assert.Equal("Firing", firing.Status)
+	assert.Equal(notification.SeverityCritical, firing.Severity)
+	assert.Equal([]notification.AlertFailure{{Check: MinInstancesCheckType, Cluster: "wdc", Observed: 0, Threshold: 1}}, firing.Failures)
+	assert.Equal([]string{MinInstancesCheckType}, firing.Checks)
+	assert.Equal(map[string]notification.ClusterCount{"wdc": {Instances: 1, Healthy: 0}}, firing.Clusters)
+	assert.Equal(2, firing.ConsecutiveFails)
+	assert.Equal(AlertFiring, m.Status().Alert)
+
+	m.App.Instances["instance1"].Healthy = true
+	m.check()
+	assert.Len(broadcaster.alerts, 2)
+	resolved := broadcaster.alerts[1]
+	assert.Equal("Resolved", resolved.Status)
+	assert.Equal(notification.SeverityInfo, resolved.Severity)
+	assert.Equal(firing.Since, resolved.Since)
+	assert.Len(resolved.Failures, 0)
+	assert.Equal([]string{MinInstancesCheckType}, resolved.Checks)
+	assert.Equal(AlertResolved, m.Status().Alert)
+
+	m.check()
+	assert.Len(broadcaster.alerts, 2)
+	assert.Equal(AlertInactive, m.Status().Alert)
+}
+
+func (suite *AlertSuite) TestResolvedChecks() {
+	assert := assert.New(suite.T())
+	broadcaster := &fakeBroadcaster{}
+	checker, err := buildCheckChain([]CheckParams{{Type: MinInstancesCheckType}, {Type: MultiTagsCheckType}}, map[string]int{"wdc": 1})
+	suite.Require().Nil(err)
+	m := &Manager{
+		id:          "app#v1",
+		Version:     "v1",
+		threshold:   1,
+		broadcaster: broadcaster,
+		alert:       alertState{repeatInterval: time.Nanosecond},
+		checkStatus: checker,
+		App:         NewAppMajor(Parameters{ID: "app", Version: "v1"}),
+	}
+
+	// La alerta se activa por min-instances y se renotifica solo por multi-tags
+	m.App.Instances["instance1"] = &Instance{ID: "instance1", ClusterID: "wdc", Healthy: false, ImageTag: "v1.0"}
+	m.check()
+	m.App.Instances["instance1"].Healthy = true
+	m.App.Instances["instance2"] = &Instance{ID: "instance2", ClusterID: "wdc", Healthy: true, ImageTag: "v1.1"}
+	time.Sleep(time.Millisecond)
+	m.check()
+	delete(m.App.Instances, "instance2")
+	m.check()
+
+	if assert.Len(broadcaster.alerts, 3) {
+		assert.Equal("Firing", broadcaster.alerts[0].Status)
+		assert.Equal([]string{MinInstancesCheckType}, broadcaster.alerts[0].Checks)
+		assert.Equal("Renotify", broadcaster.alerts[1].Status)
+		assert.Equal([]string{MultiTagsCheckType}, broadcaster.alerts[1].Checks)
+		assert.Equal("Resolved", broadcaster.alerts[2].Status)
+		assert.Equal([]string{MinInstancesCheckType, MultiTagsCheckType}, broadcaster.alerts[2].Checks)
+	}
+}
+
+type statusNotification struct {
+	mux      sync.Mutex
+	statuses []string
+}
+
+func (n *statusNotification) ID() string { return "critical" }
+
+func (n *statusNotification) Notify(alert *notification.Alert) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.statuses = append(n.statuses, alert.Status)
+	return nil
+}
+
+func (suite *AlertSuite) TestResolvedRouting() {
+	assert := assert.New(suite.T())
+	n := &statusNotification{}
+	broadcaster := report.NewBroadcaster(1, time.Millisecond, time.Millisecond)
+	assert.Nil(broadcaster.Register(n))
+	assert.Nil(broadcaster.SetRoutes([]report.Route{
+		{Severities: []string{"critical"}, Clusters: []string{"wdc"}, Providers: []string{"critical"}},
+	}))
+
+	m := &Manager{
+		id:          "app#v1",
+		Version:     "v1",
+		threshold:   1,
+		broadcaster: broadcaster,
+		alert:       alertState{repeatInterval: time.Nanosecond},
+		checkStatus: &MinInstancesCheck{MinInstancesPerCluster: map[string]int{"wdc": 2, "dal": 1}},
+		App:         NewAppMajor(Parameters{ID: "app", Version: "v1"}),
+	}
+	m.App.Instances["instance1"] = &Instance{ID: "instance1", ClusterID: "wdc", Healthy: false}
+	m.App.Instances["instance2"] = &Instance{ID: "instance2", ClusterID: "wdc", Healthy: false}
+	m.App.Instances["instance3"] = &Instance{ID: "instance3", ClusterID: "dal", Healthy: true}
+
+	// La alerta se activa como critica y luego baja a warning, pero Renotify y Resolved
+	// deben llegar a la ruta que recibio la alerta activa
+	m.check()
+	m.App.Instances["instance1"].Healthy = true
+	time.Sleep(time.Millisecond)
+	m.check()
+	m.App.Instances["instance2"].Healthy = true
+	m.check()
+	assert.True(broadcaster.Stop(time.Second))
+
+	// Cada notificacion se entrega en su propia goroutine, por lo que no se valida el orden
+	assert.ElementsMatch([]string{"Firing", "Renotify", "Resolved"}, n.statuses)
+}
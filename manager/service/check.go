@@ -1,24 +1,154 @@
 package service
 
-import "github.com/ch3lo/overlord/logger"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ch3lo/overlord/logger"
+)
 
 type Checker interface {
 	id() string
-	check(manager *Manager) bool
-	Ok(manager *Manager) bool
+	check(manager *Manager) []CheckFailure
+	Result(manager *Manager) CheckResult
+	SetNext(next Checker)
 	next() Checker
 }
 
-func checkHandler(c Checker, manager *Manager) bool {
-	logger.Instance().Infoln("Handling Check", c.id())
-	if c.check(manager) {
-		if c.next() != nil {
-			logger.Instance().Infoln("Checking", c.next().id())
-			return c.next().check(manager)
+// CheckFailure describe un chequeo que no se cumplio
+// Observed es el valor observado y Threshold el valor esperado por el chequeo
+// Cluster es vacio si el chequeo no es por cluster y Host si no es por host
+type CheckFailure struct {
+	Check     string
+	Cluster   string
+	Host      string
+	Observed  int
+	Threshold int
+}
+
+func (f CheckFailure) String() string {
+	if f.Host != "" {
+		return fmt.Sprintf("%s en el host %s del cluster %s: observado %d, umbral %d", f.Check, f.Host, f.Cluster, f.Observed, f.Threshold)
+	}
+	if f.Cluster == "" {
+		return fmt.Sprintf("%s: observado %d, umbral %d", f.Check, f.Observed, f.Threshold)
+	}
+	return fmt.Sprintf("%s en el cluster %s: observado %d, umbral %d", f.Check, f.Cluster, f.Observed, f.Threshold)
+}
+
+// CheckResult es el resultado de evaluar toda la cadena de chequeos de un Manager
+type CheckResult struct {
+	Date     time.Time
+	Failures []CheckFailure
+}
+
+// Ok indica si se cumplieron todos los chequeos
+func (r CheckResult) Ok() bool {
+	return len(r.Failures) == 0
+}
+
+func (r CheckResult) String() string {
+	if r.Ok() {
+		return "Todos los chequeos se cumplieron"
+	}
+
+	failures := make([]string, 0, len(r.Failures))
+	for _, f := range r.Failures {
+		failures = append(failures, f.String())
+	}
+	return "Chequeos fallidos: " + strings.Join(failures, "; ")
+}
+
+const (
+	// MinInstancesCheckType chequea el minimo de instancias saludables por cluster
+	MinInstancesCheckType = "min-instances"
+	// AtLeastXHostCheckType chequea el minimo de hosts por cluster que ejecutan el servicio
+	AtLeastXHostCheckType = "unique-host"
+	// MultiTagsCheckType chequea que no haya instancias saludables con distintos tags de imagen
+	MultiTagsCheckType = "multi-tags"
+	// MaxInstancesPerHostCheckType chequea el maximo de instancias saludables por host
+	MaxInstancesPerHostCheckType = "max-instances-per-host"
+)
+
+// defaultChecks es la cadena de chequeos que se utiliza si no se configura otra
+var defaultChecks = []CheckParams{
+	{Type: MinInstancesCheckType},
+	{Type: AtLeastXHostCheckType, MinHosts: 2},
+}
+
+// buildCheckChain crea los checkers en el orden de checks y los encadena
+// minInstances son las instancias minimas por cluster que utiliza MinInstancesCheck
+func buildCheckChain(checks []CheckParams, minInstances map[string]int) (Checker, error) {
+	if len(checks) == 0 {
+		checks = defaultChecks
+	}
+
+	var first, last Checker
+	for _, params := range checks {
+		c, err := newChecker(params, minInstances)
+		if err != nil {
+			return nil, err
+		}
+
+		if first == nil {
+			first = c
+		} else {
+			last.SetNext(c)
+		}
+		last = c
+	}
+	return first, nil
+}
+
+func newChecker(params CheckParams, minInstances map[string]int) (Checker, error) {
+	switch params.Type {
+	case MinInstancesCheckType:
+		return &MinInstancesCheck{MinInstancesPerCluster: minInstances}, nil
+	case AtLeastXHostCheckType:
+		if params.MinHosts < 0 {
+			return nil, &InvalidCheckParams{Type: params.Type, Message: "min_hosts no puede ser negativo"}
+		}
+		for _, v := range params.MinHostsPerCluster {
+			if v < 0 {
+				return nil, &InvalidCheckParams{Type: params.Type, Message: "min_hosts_per_cluster no puede ser negativo"}
+			}
+		}
+		minHosts := params.MinHosts
+		if minHosts == 0 {
+			minHosts = 2
+		}
+		return &AtLeastXHostCheck{MinHosts: minHosts, MinHostsPerCluster: params.MinHostsPerCluster}, nil
+	case MaxInstancesPerHostCheckType:
+		if params.MaxInstancesPerHost < 1 {
+			return nil, &InvalidCheckParams{Type: params.Type, Message: "max_instances_per_host debe ser mayor a 0"}
 		}
-		return true
+		return &MaxInstancesPerHostCheck{MaxInstancesPerHost: params.MaxInstancesPerHost}, nil
+	case MultiTagsCheckType:
+		return &MultiTagsChecker{}, nil
+	}
+	return nil, &InvalidCheckParams{Type: params.Type, Message: "tipo de chequeo desconocido"}
+}
+
+// checkHandler evalua cada uno de los checkers de la cadena desde c y acumula sus fallas
+func checkHandler(c Checker, manager *Manager) CheckResult {
+	result := CheckResult{Date: time.Now()}
+	for link := c; link != nil; link = link.next() {
+		logger.Instance().Debugln("Checking", link.id())
+		result.Failures = append(result.Failures, link.check(manager)...)
+	}
+	return result
+}
+
+// sortedKeys retorna las llaves de m ordenadas para reportar las fallas en orden estable
+func sortedKeys(m map[string]int) []string {
+	clusters := make([]string, 0, len(m))
+	for k := range m {
+		clusters = append(clusters, k)
 	}
-	return false
+	sort.Strings(clusters)
+	return clusters
 }
 
 type MultiTagsChecker struct {
@@ -26,7 +156,7 @@ type MultiTagsChecker struct {
 }
 
 func (s *MultiTagsChecker) id() string {
-	return "multi-tags"
+	return MultiTagsCheckType
 }
 
 func (c *MultiTagsChecker) SetNext(next Checker) {
@@ -37,11 +167,11 @@ func (c *MultiTagsChecker) next() Checker {
 	return c.nextChecker
 }
 
-func (c *MultiTagsChecker) Ok(manager *Manager) bool {
+func (c *MultiTagsChecker) Result(manager *Manager) CheckResult {
 	return checkHandler(c, manager)
 }
 
-func (c *MultiTagsChecker) check(manager *Manager) bool {
+func (c *MultiTagsChecker) check(manager *Manager) []CheckFailure {
 	tags := make(map[string]bool)
 	for _, v := range manager.App.Instances {
 		if v.Healthy {
@@ -51,7 +181,10 @@ func (c *MultiTagsChecker) check(manager *Manager) bool {
 
 	logger.Instance().WithField("manager_id", manager.ID()).Debugf("Version %s Has multitags %t", manager.Version, len(tags) > 1)
 
-	return len(tags) > 1
+	if len(tags) > 1 {
+		return []CheckFailure{{Check: c.id(), Observed: len(tags), Threshold: 1}}
+	}
+	return nil
 }
 
 type MinInstancesCheck struct {
@@ -60,7 +193,7 @@ type MinInstancesCheck struct {
 }
 
 func (s *MinInstancesCheck) id() string {
-	return "min-instances"
+	return MinInstancesCheckType
 }
 
 func (c *MinInstancesCheck) SetNext(next Checker) {
@@ -71,11 +204,11 @@ func (c *MinInstancesCheck) next() Checker {
 	return c.nextChecker
 }
 
-func (c *MinInstancesCheck) Ok(manager *Manager) bool {
+func (c *MinInstancesCheck) Result(manager *Manager) CheckResult {
 	return checkHandler(c, manager)
 }
 
-func (s *MinInstancesCheck) check(manager *Manager) bool {
+func (s *MinInstancesCheck) check(manager *Manager) []CheckFailure {
 	instancesPerCluster := make(map[string]int)
 	for _, v := range manager.App.Instances {
 		if v.Healthy {
@@ -83,23 +216,26 @@ func (s *MinInstancesCheck) check(manager *Manager) bool {
 		}
 	}
 
-	for clusterId, minInstances := range s.MinInstancesPerCluster {
+	var failures []CheckFailure
+	for _, clusterId := range sortedKeys(s.MinInstancesPerCluster) {
+		minInstances := s.MinInstancesPerCluster[clusterId]
 		if instancesPerCluster[clusterId] < minInstances {
 			logger.Instance().WithField("manager_id", manager.ID()).Errorf("No hay un minimo de instancias para el cluster %s servicio %v", clusterId, manager)
-			return false
+			failures = append(failures, CheckFailure{Check: s.id(), Cluster: clusterId, Observed: instancesPerCluster[clusterId], Threshold: minInstances})
 		}
 	}
 
-	return true
+	return failures
 }
 
 type AtLeastXHostCheck struct {
-	nextChecker Checker
-	MinHosts    int
+	nextChecker        Checker
+	MinHosts           int
+	MinHostsPerCluster map[string]int // minimo de hosts por cluster, reemplaza a MinHosts en esos clusters
 }
 
 func (s *AtLeastXHostCheck) id() string {
-	return "unique-host"
+	return AtLeastXHostCheckType
 }
 
 func (c *AtLeastXHostCheck) SetNext(next Checker) {
@@ -110,26 +246,92 @@ func (c *AtLeastXHostCheck) next() Checker {
 	return c.nextChecker
 }
 
-func (c *AtLeastXHostCheck) Ok(manager *Manager) bool {
+func (c *AtLeastXHostCheck) Result(manager *Manager) CheckResult {
 	return checkHandler(c, manager)
 }
 
-func (s *AtLeastXHostCheck) check(manager *Manager) bool {
-	hostsPerCluster := make(map[string]map[string]int)
-	for _, v := range manager.App.Instances {
-		if v.Healthy {
-			if _, ok := hostsPerCluster[v.ClusterID]; !ok {
-				hostsPerCluster[v.ClusterID] = make(map[string]int)
-			}
-			hostsPerCluster[v.ClusterID][v.Host]++
+func (s *AtLeastXHostCheck) minHosts(clusterId string) int {
+	if minHosts, ok := s.MinHostsPerCluster[clusterId]; ok {
+		return minHosts
+	}
+	return s.MinHosts
+}
+
+func (s *AtLeastXHostCheck) check(manager *Manager) []CheckFailure {
+	hostsCount := make(map[string]int)
+	for k, v := range healthyInstancesPerHost(manager) {
+		hostsCount[k] = len(v)
+	}
+	for k, v := range s.MinHostsPerCluster {
+		if _, ok := hostsCount[k]; !ok && v > 0 {
+			hostsCount[k] = 0
 		}
 	}
 
-	for k, v := range hostsPerCluster {
-		if len(v) < s.MinHosts {
+	var failures []CheckFailure
+	for _, k := range sortedKeys(hostsCount) {
+		minHosts := s.minHosts(k)
+		if hostsCount[k] < minHosts {
 			logger.Instance().WithField("manager_id", manager.ID()).Errorf("No hay un minimo de servidores en el cluster %s ejecutando el servicio %v", k, manager)
-			return false
+			failures = append(failures, CheckFailure{Check: s.id(), Cluster: k, Observed: hostsCount[k], Threshold: minHosts})
+		}
+	}
+	return failures
+}
+
+// MaxInstancesPerHostCheck es un chequeo de anti-afinidad que falla si un host
+// ejecuta mas de MaxInstancesPerHost instancias saludables del servicio
+type MaxInstancesPerHostCheck struct {
+	nextChecker         Checker
+	MaxInstancesPerHost int
+}
+
+func (s *MaxInstancesPerHostCheck) id() string {
+	return MaxInstancesPerHostCheckType
+}
+
+func (c *MaxInstancesPerHostCheck) SetNext(next Checker) {
+	c.nextChecker = next
+}
+
+func (c *MaxInstancesPerHostCheck) next() Checker {
+	return c.nextChecker
+}
+
+func (c *MaxInstancesPerHostCheck) Result(manager *Manager) CheckResult {
+	return checkHandler(c, manager)
+}
+
+func (s *MaxInstancesPerHostCheck) check(manager *Manager) []CheckFailure {
+	instancesPerHost := healthyInstancesPerHost(manager)
+
+	clusters := make(map[string]int)
+	for k := range instancesPerHost {
+		clusters[k] = 0
+	}
+
+	var failures []CheckFailure
+	for _, k := range sortedKeys(clusters) {
+		for _, host := range sortedKeys(instancesPerHost[k]) {
+			if instances := instancesPerHost[k][host]; instances > s.MaxInstancesPerHost {
+				logger.Instance().WithField("manager_id", manager.ID()).Errorf("El servidor %s del cluster %s ejecuta %d instancias del servicio %v", host, k, instances, manager)
+				failures = append(failures, CheckFailure{Check: s.id(), Cluster: k, Host: host, Observed: instances, Threshold: s.MaxInstancesPerHost})
+			}
+		}
+	}
+	return failures
+}
+
+// healthyInstancesPerHost cuenta las instancias saludables de cada host agrupadas por cluster
+func healthyInstancesPerHost(manager *Manager) map[string]map[string]int {
+	instancesPerHost := make(map[string]map[string]int)
+	for _, v := range manager.App.Instances {
+		if v.Healthy {
+			if _, ok := instancesPerHost[v.ClusterID]; !ok {
+				instancesPerHost[v.ClusterID] = make(map[string]int)
+			}
+			instancesPerHost[v.ClusterID][v.Host]++
 		}
 	}
-	return true
+	return instancesPerHost
 }
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestCheck(t *testing.T) {
+	suite.Run(t, new(CheckSuite))
+}
+
+type CheckSuite struct {
+	suite.Suite
+	manager *Manager
+}
+
+func (suite *CheckSuite) SetupTest() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	suite.manager = &Manager{id: "app#v1", Version: "v1", App: NewAppMajor(Parameters{ID: "app", Version: "v1"})}
+	suite.manager.App.Instances["instance1"] = &Instance{ID: "instance1", ClusterID: "wdc", Host: "thor1", ImageTag: "tag-1", Healthy: true}
+	suite.manager.App.Instances["instance2"] = &Instance{ID: "instance2", ClusterID: "wdc", Host: "thor1", ImageTag: "tag-1", Healthy: true}
+}
+
+func (suite *CheckSuite) chainIds(c Checker) []string {
+	var ids []string
+	for ; c != nil; c = c.next() {
+		ids = append(ids, c.id())
+	}
+	return ids
+}
+
+func (suite *CheckSuite) TestDefaultChain() {
+	assert := assert.New(suite.T())
+	c, err := buildCheckChain(nil, map[string]int{"wdc": 1})
+	assert.Nil(err)
+	assert.Equal([]string{MinInstancesCheckType, AtLeastXHostCheckType}, suite.chainIds(c))
+	assert.False(c.Result(suite.manager).Ok())
+}
+
+func (suite *CheckSuite) TestConfiguredChain() {
+	assert := assert.New(suite.T())
+	c, err := buildCheckChain([]CheckParams{
+		{Type: MultiTagsCheckType},
+		{Type: AtLeastXHostCheckType, MinHosts: 1},
+		{Type: MinInstancesCheckType},
+	}, map[string]int{"wdc": 2})
+	assert.Nil(err)
+	assert.Equal([]string{MultiTagsCheckType, AtLeastXHostCheckType, MinInstancesCheckType}, suite.chainIds(c))
+	assert.True(c.Result(suite.manager).Ok())
+}
+
+func (suite *CheckSuite) TestReportsAllFailures() {
+	assert := assert.New(suite.T())
+	c, err := buildCheckChain([]CheckParams{
+		{Type: MinInstancesCheckType},
+		{Type: MultiTagsCheckType},
+		{Type: AtLeastXHostCheckType, MinHosts: 2},
+	}, map[string]int{"wdc": 3, "dal": 1})
+	assert.Nil(err)
+
+	suite.manager.App.Instances["instance2"].ImageTag = "tag-2"
+	result := c.Result(suite.manager)
+	assert.False(result.Ok())
+	assert.Equal([]CheckFailure{
+		{Check: MinInstancesCheckType, Cluster: "dal", Observed: 0, Threshold: 1},
+		{Check: MinInstancesCheckType, Cluster: "wdc", Observed: 2, Threshold: 3},
+		{Check: MultiTagsCheckType, Observed: 2, Threshold: 1},
+		{Check: AtLeastXHostCheckType, Cluster: "wdc", Observed: 1, Threshold: 2},
+	}, result.Failures)
+	assert.Equal("unique-host en el cluster wdc: observado 1, umbral 2", result.Failures[3].String())
+}
+
+func (suite *CheckSuite) TestMultiTags() {
+	assert := assert.New(suite.T())
+	c := &MultiTagsChecker{}
+	assert.True(c.Result(suite.manager).Ok())
+
+	suite.manager.App.Instances["instance2"].ImageTag = "tag-2"
+	assert.False(c.Result(suite.manager).Ok())
+
+	suite.manager.App.Instances["instance2"].Healthy = false
+	assert.True(c.Result(suite.manager).Ok())
+}
+
+func (suite *CheckSuite) TestMinHostsPerCluster() {
+	assert := assert.New(suite.T())
+	c, err := buildCheckChain([]CheckParams{
+		{Type: AtLeastXHostCheckType, MinHostsPerCluster: map[string]int{"wdc": 1, "dal": 1}},
+	}, nil)
+	assert.Nil(err)
+	assert.Equal([]CheckFailure{
+		{Check: AtLeastXHostCheckType, Cluster: "dal", Observed: 0, Threshold: 1},
+	}, c.Result(suite.manager).Failures)
+
+	suite.manager.App.Instances["instance3"] = &Instance{ID: "instance3", ClusterID: "dal", Host: "thor2", Healthy: true}
+	assert.True(c.Result(suite.manager).Ok())
+}
+
+func (suite *CheckSuite) TestMaxInstancesPerHost() {
+	assert := assert.New(suite.T())
+	c, err := buildCheckChain([]CheckParams{{Type: MaxInstancesPerHostCheckType, MaxInstancesPerHost: 1}}, nil)
+	assert.Nil(err)
+	assert.Equal([]CheckFailure{
+		{Check: MaxInstancesPerHostCheckType, Cluster: "wdc", Host: "thor1", Observed: 2, Threshold: 1},
+	}, c.Result(suite.manager).Failures)
+
+	suite.manager.App.Instances["instance2"].Host = "thor2"
+	assert.True(c.Result(suite.manager).Ok())
+}
+
+func (suite *CheckSuite) TestInvalidChecks() {
+	assert := assert.New(suite.T())
+	_, err := buildCheckChain([]CheckParams{{Type: "unknown"}}, nil)
+	assert.IsType(&InvalidCheckParams{}, err)
+
+	_, err = buildCheckChain([]CheckParams{{Type: AtLeastXHostCheckType, MinHosts: -1}}, nil)
+	assert.IsType(&InvalidCheckParams{}, err)
+
+	_, err = buildCheckChain([]CheckParams{{Type: MaxInstancesPerHostCheckType}}, nil)
+	assert.IsType(&InvalidCheckParams{}, err)
+}
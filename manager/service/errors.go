@@ -21,6 +21,16 @@ func (err ManagerAlreadyExist) Error() string {
 	return fmt.Sprintf("El manager %s del servicio %s ya existe", err.Version, err.Service)
 }
 
+// ManagerNotFound sucede cuando se busca un manager de servicio que no esta registrado
+type ManagerNotFound struct {
+	Service string
+	Version string
+}
+
+func (err ManagerNotFound) Error() string {
+	return fmt.Sprintf("El manager %s del servicio %s no existe", err.Version, err.Service)
+}
+
 // ImageNameRegexpError se lanza cuando no se puede compilar el nombre de la imagen como expresion regular
 type ImageNameRegexpError struct {
 	Regexp  string
@@ -30,3 +40,23 @@ type ImageNameRegexpError struct {
 func (err ImageNameRegexpError) Error() string {
 	return fmt.Sprintf("No se pudo compilar el nombre de la imagen %s como expresion regular: %s", err.Regexp, err.Message)
 }
+
+// InvalidCheckParams sucede cuando no se puede crear un checker con los parametros entregados
+type InvalidCheckParams struct {
+	Type    string
+	Message string
+}
+
+func (err InvalidCheckParams) Error() string {
+	return fmt.Sprintf("Chequeo %s invalido: %s", err.Type, err.Message)
+}
+
+// MissingCriteria sucede cuando un servicio no define el nombre de la imagen ni una expresion de criterios
+type MissingCriteria struct {
+	Service string
+	Version string
+}
+
+func (err MissingCriteria) Error() string {
+	return fmt.Sprintf("El manager %s del servicio %s debe definir image_name o criteria", err.Version, err.Service)
+}
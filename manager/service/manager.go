@@ -1,7 +1,7 @@
 package service
 
 import (
-	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,12 +10,25 @@ import (
 	"github.com/ch3lo/overlord/logger"
 	"github.com/ch3lo/overlord/manager/report"
 	"github.com/ch3lo/overlord/monitor"
+	"github.com/ch3lo/overlord/notification"
 )
 
 type serviceStatus struct {
 	success          int
 	failed           int
 	consecutiveFails int
+	lastResult       CheckResult
+}
+
+// CheckStatus es una copia de solo lectura del estado de los chequeos de un Manager
+type CheckStatus struct {
+	Success          int
+	Failed           int
+	ConsecutiveFails int
+	Threshold        int
+	LastResult       CheckResult
+	Alert            AlertStatus
+	AlertSince       time.Time
 }
 
 // Manager es una estructura que contiene la información de una
@@ -30,12 +43,18 @@ type Manager struct {
 	broadcaster        report.Broadcast
 	threshold          int // limite de checks antes de marcar el servicio como fallido
 	status             serviceStatus
+	alert              alertState
+	alertChecks        []string              // chequeos que fallaron desde que se activo la alerta
+	alertSeverity      notification.Severity // severidad con que se enruta la alerta activa
+	alertClusters      []string              // clusters con que se enruta la alerta activa
 	checkStatus        Checker
 	App                *AppMajor
 }
 
 // NewServiceManager instancia un nuevo Manager y el chequeo de los servicios asociados
-func NewServiceManager(clusterNames []string, checkConfig configuration.Check, broadcaster report.Broadcast, params Parameters) (*Manager, error) {
+// La cadena de chequeos es la de la registracion o, si no tiene, la configurada para los managers
+func NewServiceManager(clusterNames []string, managerConfig configuration.Manager, broadcaster report.Broadcast, params Parameters) (*Manager, error) {
+	checkConfig := managerConfig.Check
 	interval := time.Second * 10
 	if checkConfig.Interval != 0 {
 		interval = checkConfig.Interval
@@ -55,24 +74,38 @@ func NewServiceManager(clusterNames []string, checkConfig configuration.Check, b
 		threshold:    threshold,
 		broadcaster:  broadcaster,
 		status:       serviceStatus{},
+		alert:        alertState{repeatInterval: checkConfig.RepeatInterval},
 		App:          NewAppMajor(params),
 	}
 
-	sm.checkStatus = sm.buildChecker(clusterNames, params)
+	checks := params.Constraints.Checks
+	if len(checks) == 0 {
+		for _, v := range managerConfig.Checks {
+			checks = append(checks, CheckParams{
+				Type:                v.Type,
+				MinHosts:            v.MinHosts,
+				MinHostsPerCluster:  v.MinHostsPerCluster,
+				MaxInstancesPerHost: v.MaxInstancesPerHost,
+			})
+		}
+	}
+
+	checker, err := sm.buildChecker(clusterNames, params, checks)
+	if err != nil {
+		return nil, err
+	}
+	sm.checkStatus = checker
 
 	return sm, nil
 }
 
-func (s *Manager) buildChecker(clusterNames []string, params Parameters) Checker {
+func (s *Manager) buildChecker(clusterNames []string, params Parameters, checks []CheckParams) (Checker, error) {
 	minInstances := make(map[string]int)
 	for _, clusterName := range clusterNames {
 		minInstances[clusterName] = params.Constraints.MinInstancesPerCluster[clusterName]
 	}
 
-	minInstancesChecker := &MinInstancesCheck{MinInstancesPerCluster: minInstances}
-	atLeastXHost := &AtLeastXHostCheck{MinHosts: 2}
-	minInstancesChecker.SetNext(atLeastXHost)
-	return minInstancesChecker
+	return buildCheckChain(checks, minInstances)
 }
 
 // ID retorna el identificador del manager el cual es:
@@ -89,17 +122,19 @@ func (s *Manager) Update(data map[string]*monitor.ServiceUpdaterData) {
 
 	for _, v := range data {
 		for _, w := range v.Origin().Instances {
-			if v.InStatus(monitor.ServiceRemoved) {
+			if v.InStatus(monitor.ServiceRemoved) || v.InStatus(monitor.ServicePurged) {
 				delete(s.App.Instances, w.ID)
 			} else {
 				instance, ok := s.App.Instances[w.ID]
 				if ok {
 					instance.Healthy = w.Healthy()
+					instance.Host = w.Host
 				} else {
 					instance = &Instance{
 						ID:           w.ID,
 						CreationDate: time.Now(),
 						Healthy:      w.Healthy(),
+						Host:         w.Host,
 						ClusterID:    v.ClusterID(),
 						ImageName:    v.Origin().ImageName,
 						ImageTag:     v.Origin().ImageTag,
@@ -116,6 +151,34 @@ func (s *Manager) Update(data map[string]*monitor.ServiceUpdaterData) {
 	}
 }
 
+// GetInstances retorna una copia de las instancias que maneja el Manager
+func (s *Manager) GetInstances() []Instance {
+	s.updateInstancesMux.Lock()
+	defer s.updateInstancesMux.Unlock()
+
+	instances := make([]Instance, 0, len(s.App.Instances))
+	for _, v := range s.App.Instances {
+		instances = append(instances, *v)
+	}
+	return instances
+}
+
+// Status retorna el estado de los chequeos del Manager
+func (s *Manager) Status() CheckStatus {
+	s.updateInstancesMux.Lock()
+	defer s.updateInstancesMux.Unlock()
+
+	return CheckStatus{
+		Success:          s.status.success,
+		Failed:           s.status.failed,
+		ConsecutiveFails: s.status.consecutiveFails,
+		Threshold:        s.threshold,
+		LastResult:       s.status.lastResult,
+		Alert:            s.alert.status,
+		AlertSince:       s.alert.since,
+	}
+}
+
 // StartCheck comienza el chequeo de los servicios
 func (s *Manager) StartCheck() {
 	logger.Instance().WithField("manager_id", s.ID()).Infoln("Comenzando check")
@@ -131,20 +194,137 @@ func (s *Manager) StopCheck() {
 }
 
 func (s *Manager) check() {
-	if s.checkStatus.Ok(s) {
+	s.updateInstancesMux.Lock()
+	result := s.checkStatus.Result(s)
+	s.status.lastResult = result
+	if result.Ok() {
 		s.status.consecutiveFails = 0
 		s.status.success++
 	} else {
 		s.status.consecutiveFails++
 		s.status.failed++
 	}
+	status := s.status
+	firingSince := s.alert.since
+	alertStatus, notify := s.alert.transition(result.Ok(), status.consecutiveFails, s.threshold, result.Date)
+
+	var alert *notification.Alert
+	if notify {
+		if alertStatus == AlertFiring {
+			firingSince = s.alert.since
+		}
+		alert = s.newAlert(alertStatus, result, firingSince)
+	}
+	s.updateInstancesMux.Unlock()
+
+	logger.Instance().WithField("manager_id", s.ID()).Debugf("Status del chequeo %+v - threshold %d - alerta %s", status, s.threshold, alertStatus)
+
+	if notify {
+		s.broadcaster.Broadcast(alert)
+	}
+}
+
+// newAlert construye el evento de alerta con el resultado del chequeo y las instancias por cluster
+// since es el momento en que la alerta comenzo a estar activa
+// Se debe llamar con updateInstancesMux tomado
+func (s *Manager) newAlert(status AlertStatus, result CheckResult, since time.Time) *notification.Alert {
+	alert := &notification.Alert{
+		ManagerID:        s.ID(),
+		App:              s.App.ID,
+		Version:          s.Version,
+		Status:           status.String(),
+		Severity:         notification.SeverityWarning,
+		Clusters:         make(map[string]notification.ClusterCount),
+		ConsecutiveFails: s.status.consecutiveFails,
+		Threshold:        s.threshold,
+		Since:            since,
+		Date:             result.Date,
+	}
+
+	for _, v := range s.App.Instances {
+		count := alert.Clusters[v.ClusterID]
+		count.Instances++
+		if v.Healthy {
+			count.Healthy++
+		}
+		alert.Clusters[v.ClusterID] = count
+	}
+
+	for _, f := range result.Failures {
+		if len(alert.Checks) == 0 || alert.Checks[len(alert.Checks)-1] != f.Check {
+			alert.Checks = append(alert.Checks, f.Check)
+		}
+		alert.Failures = append(alert.Failures, notification.AlertFailure{
+			Check:     f.Check,
+			Cluster:   f.Cluster,
+			Host:      f.Host,
+			Observed:  f.Observed,
+			Threshold: f.Threshold,
+		})
+		if f.Check == MinInstancesCheckType && f.Observed == 0 {
+			alert.Severity = notification.SeverityCritical
+		}
+	}
+
+	// La ruta y los chequeos de la alerta se acumulan mientras esta activa: Renotify puede
+	// agregar clusters o chequeos o escalar la severidad, y Resolved se enruta igual que la
+	// ultima alerta activa e informa todos los chequeos que fallaron
+	switch status {
+	case AlertFiring:
+		s.alertSeverity = alert.Severity
+		s.alertClusters = nil
+		s.alertChecks = nil
+		s.addAlertClusters(alert.Failures)
+		s.addAlertChecks(alert.Checks)
+	case AlertRenotify:
+		if alert.Severity == notification.SeverityCritical {
+			s.alertSeverity = alert.Severity
+		}
+		s.addAlertClusters(alert.Failures)
+		s.addAlertChecks(alert.Checks)
+	case AlertResolved:
+		alert.Severity = notification.SeverityInfo
+		alert.Checks = append([]string(nil), s.alertChecks...)
+	}
+	alert.RouteSeverity = s.alertSeverity
+	alert.RouteClusters = append([]string(nil), s.alertClusters...)
+	return alert
+}
 
-	logger.Instance().WithField("manager_id", s.ID()).Debugf("Status del chequeo %+v - threshold %d", s.status, s.threshold)
+// addAlertChecks agrega a la alerta activa los chequeos que aun no habian fallado
+func (s *Manager) addAlertChecks(checks []string) {
+	for _, check := range checks {
+		found := false
+		for _, c := range s.alertChecks {
+			if c == check {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.alertChecks = append(s.alertChecks, check)
+		}
+	}
+}
 
-	if s.threshold == s.status.consecutiveFails {
-		var query = []byte(fmt.Sprintf("Status del chequeo %+v - threshold %d", s.status, s.threshold))
-		s.broadcaster.Broadcast(query)
+// addAlertClusters agrega a la ruta de la alerta activa los clusters de las fallas
+func (s *Manager) addAlertClusters(failures []notification.AlertFailure) {
+	for _, f := range failures {
+		if f.Cluster == "" {
+			continue
+		}
+		found := false
+		for _, c := range s.alertClusters {
+			if c == f.Cluster {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.alertClusters = append(s.alertClusters, f.Cluster)
+		}
 	}
+	sort.Strings(s.alertClusters)
 }
 
 func (s *Manager) checkInstances() {
@@ -8,19 +8,37 @@ import (
 )
 
 type ConstraintsParams struct {
-	ImageName              string
-	MinInstancesPerCluster map[string]int
+	ImageName              string         `json:"image_name,omitempty"`
+	MinInstancesPerCluster map[string]int `json:"min_instances_per_cluster,omitempty"`
+	Criteria               string         `json:"criteria,omitempty"` // expresion interpretada por monitor.ParseCriteria
+	Checks                 []CheckParams  `json:"checks,omitempty"`   // cadena de chequeos, si es vacia se usa la del manager
+}
+
+// CheckParams configura un checker de la cadena de chequeos de un Manager
+// MinHosts y MinHostsPerCluster aplican solo al chequeo unique-host
+// MaxInstancesPerHost aplica solo al chequeo max-instances-per-host
+type CheckParams struct {
+	Type                string         `json:"type"`
+	MinHosts            int            `json:"min_hosts,omitempty"`
+	MinHostsPerCluster  map[string]int `json:"min_hosts_per_cluster,omitempty"`
+	MaxInstancesPerHost int            `json:"max_instances_per_host,omitempty"`
 }
 
 // Parameters es una estructura que encapsula los parametros
 // de configuración de un nuevo servicio
 type Parameters struct {
-	ID          string
-	Version     string
-	Constraints ConstraintsParams
+	ID          string            `json:"id"`
+	Version     string            `json:"version"`
+	Constraints ConstraintsParams `json:"constraints"`
 }
 
+// BuildCriteria construye el criterio con el que se filtran los servicios del manager
+// Se requiere ImageName, Criteria o ambos, en cuyo caso se deben cumplir los dos
 func (p *Parameters) BuildCriteria() (monitor.ServiceChangeCriteria, error) {
+	if p.Constraints.ImageName == "" && p.Constraints.Criteria == "" {
+		return nil, &MissingCriteria{Service: p.ID, Version: p.Version}
+	}
+
 	var criteria monitor.ServiceChangeCriteria
 	if p.Constraints.ImageName != "" {
 		reg := "^" + p.Constraints.ImageName
@@ -30,6 +48,17 @@ func (p *Parameters) BuildCriteria() (monitor.ServiceChangeCriteria, error) {
 		}
 		criteria = &monitor.ImageNameAndImageTagRegexpCriteria{imageNameRegexp}
 	}
+
+	if p.Constraints.Criteria != "" {
+		expressionCriteria, err := monitor.ParseCriteria(p.Constraints.Criteria)
+		if err != nil {
+			return nil, err
+		}
+		if criteria == nil {
+			return expressionCriteria, nil
+		}
+		criteria = monitor.NewAndCriteria(criteria, expressionCriteria)
+	}
 	return criteria, nil
 }
 
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ch3lo/overlord/monitor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCriteria(t *testing.T) {
+	assert := assert.New(t)
+
+	params := Parameters{ID: "app", Version: "v1"}
+	_, err := params.BuildCriteria()
+	assert.IsType(new(MissingCriteria), err)
+
+	params.Constraints.ImageName = "registry.com/app"
+	criteria, err := params.BuildCriteria()
+	assert.Nil(err)
+	assert.IsType(new(monitor.ImageNameAndImageTagRegexpCriteria), criteria)
+
+	params.Constraints.ImageName = ""
+	params.Constraints.Criteria = "cluster == wdc"
+	criteria, err = params.BuildCriteria()
+	assert.Nil(err)
+	assert.NotNil(criteria)
+
+	params.Constraints.ImageName = "registry.com/app"
+	criteria, err = params.BuildCriteria()
+	assert.Nil(err)
+	assert.IsType(new(monitor.AndCriteria), criteria)
+
+	params.Constraints.ImageName = "("
+	_, err = params.BuildCriteria()
+	assert.IsType(new(ImageNameRegexpError), err)
+}
@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ch3lo/overlord/cluster"
+	"github.com/latam-airlines/mesos-framework-factory"
+)
+
+// clusterPoller consulta los servicios del scheduler de un cluster
+// Cada cluster tiene su propio intervalo y tiempo maximo de espera para que un scheduler
+// lento no retrase la deteccion de cambios en el resto de los clusters
+// find obtiene los servicios del scheduler, por defecto desde el scheduler del cluster
+type clusterPoller struct {
+	mux      sync.Mutex
+	id       string
+	find     func() ([]*framework.ServiceInformation, error)
+	interval time.Duration
+	timeout  time.Duration
+	pending  bool
+}
+
+// newClusterPoller crea un clusterPoller que consulta el scheduler del cluster c
+func newClusterPoller(id string, c *cluster.Cluster, interval time.Duration, timeout time.Duration) *clusterPoller {
+	return &clusterPoller{
+		id:       id,
+		find:     func() ([]*framework.ServiceInformation, error) { return c.GetScheduler().FindServiceInformation(nil) },
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+// clusterPollResult resultado de la consulta de un clusterPoller
+type clusterPollResult struct {
+	clusterID string
+	services  []*framework.ServiceInformation
+	err       error
+}
+
+// poll obtiene los servicios del scheduler esperando como maximo el timeout del cluster
+// o hasta que se cancele ctx. Si la consulta anterior aun no responde no se realiza una
+// nueva, para no acumular goroutines bloqueadas en un scheduler que no responde
+func (p *clusterPoller) poll(ctx context.Context) ([]*framework.ServiceInformation, error) {
+	p.mux.Lock()
+	if p.pending {
+		p.mux.Unlock()
+		return nil, errors.New("La consulta anterior al scheduler aun no responde")
+	}
+	p.pending = true
+	p.mux.Unlock()
+
+	result := make(chan clusterPollResult, 1)
+	go func() {
+		srvs, err := p.find()
+
+		p.mux.Lock()
+		p.pending = false
+		p.mux.Unlock()
+
+		result <- clusterPollResult{clusterID: p.id, services: srvs, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.services, r.err
+	case <-time.After(p.timeout):
+		return nil, fmt.Errorf("El scheduler no respondio en %s", p.timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
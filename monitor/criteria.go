@@ -43,20 +43,135 @@ func (c *StatusCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) m
 	return filtered
 }
 
-// HealthyCriteria es un filtro que aplica criterios sobre la salud de un servicio
+// HealthyMode es el modo en que HealthyCriteria evalua la salud de las instancias de un servicio
+type HealthyMode int
+
+const (
+	// AllHealthy todas las instancias del servicio estan saludables (y existe al menos una)
+	AllHealthy HealthyMode = 1 + iota
+	// AnyUnhealthy al menos una instancia del servicio no esta saludable
+	AnyUnhealthy
+	// AtLeastHealthy al menos MinHealthy instancias del servicio estan saludables
+	AtLeastHealthy
+)
+
+// HealthyCriteria es un filtro que aplica criterios sobre la salud de las instancias de un servicio
 type HealthyCriteria struct {
-	Status bool
+	Mode       HealthyMode
+	MinHealthy int
 }
 
 // MeetCriteria aplica el filtro HealthyCriteria y retorna un map[string]*ServiceUpdaterData
 // con aquellos servicios que cumplen con el criterio
 func (c *HealthyCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
 	filtered := make(map[string]*ServiceUpdaterData)
-	/*	for k, v := range elements {
-		if v.Origin().Healthy() == c.Status {
+	for k, v := range elements {
+		if v.Origin() == nil {
+			continue
+		}
+
+		healthy := 0
+		for _, instance := range v.Origin().Instances {
+			if instance.Healthy() {
+				healthy++
+			}
+		}
+		total := len(v.Origin().Instances)
+
+		var meet bool
+		switch c.Mode {
+		case AllHealthy:
+			meet = total > 0 && healthy == total
+		case AnyUnhealthy:
+			meet = healthy < total
+		case AtLeastHealthy:
+			meet = healthy >= c.MinHealthy
+		}
+
+		if meet {
+			filtered[k] = elements[k]
+		}
+	}
+	return filtered
+}
+
+// FlappingCriteria es un filtro que aplica criterios sobre la intermitencia de un servicio
+type FlappingCriteria struct {
+	Flapping bool
+}
+
+// MeetCriteria aplica el filtro FlappingCriteria y retorna un map[string]*ServiceUpdaterData
+// con aquellos servicios que cumplen con el criterio
+func (c *FlappingCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
+	filtered := make(map[string]*ServiceUpdaterData)
+	for k, v := range elements {
+		if v.Flapping() == c.Flapping {
 			filtered[k] = elements[k]
 		}
-	}*/
+	}
+	return filtered
+}
+
+// ClusterCriteria es un filtro que selecciona los servicios de alguno de los clusters Clusters
+type ClusterCriteria struct {
+	Clusters []string
+}
+
+// MeetCriteria aplica el filtro ClusterCriteria y retorna un map[string]*ServiceUpdaterData
+// con aquellos servicios que cumplen con el criterio
+func (c *ClusterCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
+	filtered := make(map[string]*ServiceUpdaterData)
+	for k, v := range elements {
+		for _, cluster := range c.Clusters {
+			if v.ClusterID() == cluster {
+				filtered[k] = elements[k]
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// HostCriteria es un filtro que selecciona los servicios con al menos una instancia
+// en un host que cumple con la expresion regular HostRegexp
+type HostCriteria struct {
+	HostRegexp *regexp.Regexp
+}
+
+// MeetCriteria aplica el filtro HostCriteria y retorna un map[string]*ServiceUpdaterData
+// con aquellos servicios que cumplen con el criterio
+func (c *HostCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
+	filtered := make(map[string]*ServiceUpdaterData)
+	for k, v := range elements {
+		if v.Origin() == nil {
+			continue
+		}
+		for _, instance := range v.Origin().Instances {
+			if c.HostRegexp.MatchString(instance.Host) {
+				filtered[k] = elements[k]
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// NotCriteria es un criterio que selecciona los servicios que no cumplen con Criteria
+type NotCriteria struct {
+	Criteria ServiceChangeCriteria
+}
+
+// MeetCriteria aplica el filtro NotCriteria y retorna un map[string]*ServiceUpdaterData
+// con aquellos servicios que no cumplen con el criterio negado
+func (c *NotCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
+	excluded := c.Criteria.MeetCriteria(elements)
+
+	filtered := make(map[string]*ServiceUpdaterData)
+	for k, v := range elements {
+		if _, ok := excluded[k]; !ok {
+			filtered[k] = v
+		}
+	}
 	return filtered
 }
 
@@ -66,6 +181,11 @@ type AndCriteria struct {
 	otherCriteria ServiceChangeCriteria
 }
 
+// NewAndCriteria crea un criterio que cumplen los servicios que cumplen ambos criterios
+func NewAndCriteria(criteria ServiceChangeCriteria, otherCriteria ServiceChangeCriteria) *AndCriteria {
+	return &AndCriteria{criteria: criteria, otherCriteria: otherCriteria}
+}
+
 // MeetCriteria aplica el filtro que tiene como objetivo realizar un && sobre dos criterios
 func (c *AndCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
 	filtered := c.criteria.MeetCriteria(elements)
@@ -78,6 +198,11 @@ type OrCriteria struct {
 	otherCriteria ServiceChangeCriteria
 }
 
+// NewOrCriteria crea un criterio que cumplen los servicios que cumplen alguno de los criterios
+func NewOrCriteria(criteria ServiceChangeCriteria, otherCriteria ServiceChangeCriteria) *OrCriteria {
+	return &OrCriteria{criteria: criteria, otherCriteria: otherCriteria}
+}
+
 // MeetCriteria aplica el filtro que tiene como objetivo realizar un || sobre dos criterios
 func (c *OrCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
 	filtered := c.criteria.MeetCriteria(elements)
@@ -6,8 +6,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/ch3lo/overlord/scheduler"
-
+	"github.com/latam-airlines/mesos-framework-factory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
@@ -31,13 +30,14 @@ func (suite *CriteriaSuite) SetupTest() {
 		lastUpdate:   date1,
 		lastAction:   ServiceAdded,
 		clusterID:    "wdc",
-		origin: scheduler.ServiceInformation{
-			ID:            "qwerty12345",
-			ImageName:     "registry.com/nombre_imagen",
-			ImageTag:      "tag-123",
-			Host:          "thor1",
-			ContainerName: "container_name1",
-			Status:        scheduler.ServiceUp,
+		origin: &framework.ServiceInformation{
+			ID:        "qwerty12345",
+			ImageName: "registry.com/nombre_imagen",
+			ImageTag:  "tag-123",
+			Instances: []*framework.Instance{
+				{ID: "instance1", Host: "thor1", ContainerName: "container_name1", Status: framework.InstanceUp},
+				{ID: "instance2", Host: "thor3", ContainerName: "container_name3", Status: framework.InstanceUp},
+			},
 		},
 	}
 
@@ -47,13 +47,14 @@ func (suite *CriteriaSuite) SetupTest() {
 		lastUpdate:   date2,
 		lastAction:   ServiceUpdated,
 		clusterID:    "dal",
-		origin: scheduler.ServiceInformation{
-			ID:            "asdasd",
-			ImageName:     "registry.com/imagen_nombre",
-			ImageTag:      "tag-234",
-			Host:          "thor2",
-			ContainerName: "container_name2",
-			Status:        scheduler.ServiceDown,
+		origin: &framework.ServiceInformation{
+			ID:        "asdasd",
+			ImageName: "registry.com/imagen_nombre",
+			ImageTag:  "tag-234",
+			Instances: []*framework.Instance{
+				{ID: "instance3", Host: "thor2", ContainerName: "container_name2", Status: framework.InstanceUp},
+				{ID: "instance4", Host: "thor2", ContainerName: "container_name4", Status: framework.InstanceDown},
+			},
 		},
 	}
 }
@@ -86,8 +87,31 @@ func (suite *CriteriaSuite) TestStatusCriteria() {
 }
 
 func (suite *CriteriaSuite) TestHealthyCriteria() {
-	suite.assertLenCriteria(&HealthyCriteria{true}, 1)
-	suite.assertLenCriteria(&HealthyCriteria{false}, 1)
+	suite.assertLenCriteria(&HealthyCriteria{Mode: AllHealthy}, 1)
+	suite.assertLenCriteria(&HealthyCriteria{Mode: AnyUnhealthy}, 1)
+	suite.assertLenCriteria(&HealthyCriteria{Mode: AtLeastHealthy, MinHealthy: 1}, 2)
+	suite.assertLenCriteria(&HealthyCriteria{Mode: AtLeastHealthy, MinHealthy: 2}, 1)
+	suite.assertLenCriteria(&HealthyCriteria{Mode: AtLeastHealthy, MinHealthy: 3}, 0)
+}
+
+func (suite *CriteriaSuite) TestClusterCriteria() {
+	suite.assertLenCriteria(&ClusterCriteria{Clusters: []string{"wdc"}}, 1)
+	suite.assertLenCriteria(&ClusterCriteria{Clusters: []string{"wdc", "dal"}}, 2)
+	suite.assertLenCriteria(&ClusterCriteria{Clusters: []string{"sjc"}}, 0)
+	suite.assertLenCriteria(&ClusterCriteria{}, 0)
+}
+
+func (suite *CriteriaSuite) TestHostCriteria() {
+	suite.assertLenCriteria(&HostCriteria{HostRegexp: regexp.MustCompile("^thor1$")}, 1)
+	suite.assertLenCriteria(&HostCriteria{HostRegexp: regexp.MustCompile("thor")}, 2)
+	suite.assertLenCriteria(&HostCriteria{HostRegexp: regexp.MustCompile("canary")}, 0)
+}
+
+func (suite *CriteriaSuite) TestNotCriteria() {
+	suite.assertLenCriteria(&NotCriteria{&ClusterCriteria{Clusters: []string{"wdc"}}}, 1)
+	suite.assertLenCriteria(&NotCriteria{&HostCriteria{HostRegexp: regexp.MustCompile("thor")}}, 0)
+	suite.assertLenCriteria(&NotCriteria{&StatusCriteria{ServiceRemoved}}, 2)
+	suite.assertLenCriteria(&NotCriteria{&NotCriteria{&StatusCriteria{ServiceAdded}}}, 1)
 }
 
 func (suite *CriteriaSuite) TestAndCriteria() {
@@ -95,8 +119,9 @@ func (suite *CriteriaSuite) TestAndCriteria() {
 		&ImageNameAndImageTagRegexpCriteria{regexp.MustCompile("nombre")},
 		&ImageNameAndImageTagRegexpCriteria{regexp.MustCompile("tag")},
 	}, 2)
-	suite.assertLenCriteria(&AndCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{true}}, 1)
-	suite.assertLenCriteria(&AndCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{false}}, 0)
+	suite.assertLenCriteria(&AndCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{Mode: AllHealthy}}, 1)
+	suite.assertLenCriteria(&AndCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{Mode: AnyUnhealthy}}, 0)
+	suite.assertLenCriteria(NewAndCriteria(&ClusterCriteria{Clusters: []string{"dal"}}, &HealthyCriteria{Mode: AnyUnhealthy}), 1)
 }
 
 func (suite *CriteriaSuite) TestOrCriteria() {
@@ -104,7 +129,70 @@ func (suite *CriteriaSuite) TestOrCriteria() {
 		&ImageNameAndImageTagRegexpCriteria{regexp.MustCompile("nombre")},
 		&ImageNameAndImageTagRegexpCriteria{regexp.MustCompile("tag")},
 	}, 2)
-	suite.assertLenCriteria(&OrCriteria{&HealthyCriteria{false}, &HealthyCriteria{true}}, 2)
-	suite.assertLenCriteria(&OrCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{true}}, 1)
-	suite.assertLenCriteria(&OrCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{false}}, 2)
+	suite.assertLenCriteria(&OrCriteria{&HealthyCriteria{Mode: AnyUnhealthy}, &HealthyCriteria{Mode: AllHealthy}}, 2)
+	suite.assertLenCriteria(&OrCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{Mode: AllHealthy}}, 1)
+	suite.assertLenCriteria(&OrCriteria{&StatusCriteria{ServiceAdded}, &HealthyCriteria{Mode: AnyUnhealthy}}, 2)
+	suite.assertLenCriteria(NewOrCriteria(&ClusterCriteria{Clusters: []string{"dal"}}, &NotCriteria{&StatusCriteria{ServiceUpdated}}), 2)
+}
+
+func (suite *CriteriaSuite) assertParseCriteria(expression string, length int) {
+	criteria, err := ParseCriteria(expression)
+	if assert.Nil(suite.T(), err, expression) {
+		suite.assertLenCriteria(criteria, length)
+	}
+}
+
+func (suite *CriteriaSuite) TestParseCriteria() {
+	suite.assertParseCriteria(`image =~ "^registry.com/nombre"`, 1)
+	suite.assertParseCriteria(`image !~ "^registry.com/nombre"`, 1)
+	suite.assertParseCriteria(`image == registry.com/imagen_nombre`, 1)
+	suite.assertParseCriteria(`tag != tag-123`, 1)
+	suite.assertParseCriteria(`id == qwerty12345`, 1)
+	suite.assertParseCriteria(`cluster in (wdc,dal)`, 2)
+	suite.assertParseCriteria(`cluster in ("wdc")`, 1)
+	suite.assertParseCriteria(`status == added`, 1)
+	suite.assertParseCriteria(`status in (ServiceAdded, updated)`, 2)
+	suite.assertParseCriteria(`flapping == false`, 2)
+	suite.assertParseCriteria(`image =~ "^registry.com" && cluster in (wdc,dal) && status == removed`, 0)
+	suite.assertParseCriteria(`status == added || cluster == dal && tag == tag-234`, 2)
+	suite.assertParseCriteria(`(status == added || cluster == dal) && tag == tag-234`, 1)
+	suite.assertParseCriteria(`!status == added`, 1)
+	suite.assertParseCriteria(`!(cluster == wdc || tag == tag-234)`, 0)
+	suite.assertParseCriteria(`host == thor1`, 1)
+	suite.assertParseCriteria(`host in (thor2, thor3)`, 2)
+	suite.assertParseCriteria(`host =~ "^thor"`, 2)
+	suite.assertParseCriteria(`host != thor1`, 1)
+	suite.assertParseCriteria(`host !~ "canary"`, 2)
+}
+
+func (suite *CriteriaSuite) TestParseCriteriaErrors() {
+	assert := assert.New(suite.T())
+	for _, expression := range []string{
+		``,
+		`image`,
+		`image ==`,
+		`owner == team`,
+		`image =~ "["`,
+		`status == unknown`,
+		`status =~ add`,
+		`flapping == maybe`,
+		`cluster in (wdc`,
+		`(cluster == wdc`,
+		`cluster == wdc &&`,
+		`cluster == wdc dal`,
+		`image == "registry`,
+		`cluster == wdc & status == added`,
+		`!`,
+		`host =~ "("`,
+	} {
+		_, err := ParseCriteria(expression)
+		assert.IsType(&CriteriaExpressionError{}, err, expression)
+	}
+
+	for _, expression := range []string{`label.team == pagos`, `cluster == wdc && env.STAGE != prod`} {
+		_, err := ParseCriteria(expression)
+		if assert.IsType(&CriteriaExpressionError{}, err, expression) {
+			assert.Contains(err.Error(), "el scheduler no informa", expression)
+		}
+	}
 }
@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/latam-airlines/mesos-framework-factory"
+)
+
+// ChangeType es el tipo de cambio detectado en un servicio entre dos consultas al scheduler
+type ChangeType int
+
+const (
+	// ImageNameChanged cambio el nombre de la imagen del servicio
+	ImageNameChanged ChangeType = 1 + iota
+	// ImageTagChanged cambio el tag de la imagen del servicio
+	ImageTagChanged
+	// InstanceAdded se agrego una instancia al servicio
+	InstanceAdded
+	// InstanceRemoved se removio una instancia del servicio
+	InstanceRemoved
+	// HealthChanged cambio el estado de salud de una instancia
+	HealthChanged
+	// HostChanged una instancia se movio de host
+	HostChanged
+)
+
+var changeTypes = [...]string{
+	"ImageNameChanged",
+	"ImageTagChanged",
+	"InstanceAdded",
+	"InstanceRemoved",
+	"HealthChanged",
+	"HostChanged",
+}
+
+func (c ChangeType) String() string {
+	if c < ImageNameChanged || int(c) > len(changeTypes) {
+		return ""
+	}
+	return changeTypes[c-1]
+}
+
+// ServiceChange describe un cambio puntual de un servicio
+// InstanceID esta vacio cuando el cambio es a nivel de servicio
+type ServiceChange struct {
+	Type       ChangeType `json:"type"`
+	InstanceID string     `json:"instance_id,omitempty"`
+	Previous   string     `json:"previous,omitempty"`
+	Current    string     `json:"current,omitempty"`
+}
+
+// diffServices calcula los cambios entre dos versiones de la informacion de un servicio
+// Si previous es nil se considera que todas las instancias de current son nuevas
+// y si current es nil que todas las instancias de previous fueron removidas
+func diffServices(previous *framework.ServiceInformation, current *framework.ServiceInformation) []ServiceChange {
+	var changes []ServiceChange
+
+	if previous != nil && current != nil {
+		if previous.ImageName != current.ImageName {
+			changes = append(changes, ServiceChange{Type: ImageNameChanged, Previous: previous.ImageName, Current: current.ImageName})
+		}
+		if previous.ImageTag != current.ImageTag {
+			changes = append(changes, ServiceChange{Type: ImageTagChanged, Previous: previous.ImageTag, Current: current.ImageTag})
+		}
+	}
+
+	previousInstances := instancesByID(previous)
+	currentInstances := instancesByID(current)
+
+	for _, id := range sortedInstanceIds(previousInstances) {
+		if _, ok := currentInstances[id]; !ok {
+			changes = append(changes, ServiceChange{Type: InstanceRemoved, InstanceID: id, Previous: previousInstances[id].Host})
+		}
+	}
+
+	for _, id := range sortedInstanceIds(currentInstances) {
+		c := currentInstances[id]
+		p, ok := previousInstances[id]
+		if !ok {
+			changes = append(changes, ServiceChange{Type: InstanceAdded, InstanceID: id, Current: c.Host})
+			continue
+		}
+
+		if p.Host != c.Host {
+			changes = append(changes, ServiceChange{Type: HostChanged, InstanceID: id, Previous: p.Host, Current: c.Host})
+		}
+		if p.Healthy() != c.Healthy() {
+			changes = append(changes, ServiceChange{
+				Type:       HealthChanged,
+				InstanceID: id,
+				Previous:   strconv.FormatBool(p.Healthy()),
+				Current:    strconv.FormatBool(c.Healthy()),
+			})
+		}
+	}
+
+	return changes
+}
+
+func instancesByID(service *framework.ServiceInformation) map[string]*framework.Instance {
+	instances := make(map[string]*framework.Instance)
+	if service == nil {
+		return instances
+	}
+	for _, v := range service.Instances {
+		instances[v.ID] = v
+	}
+	return instances
+}
+
+func sortedInstanceIds(instances map[string]*framework.Instance) []string {
+	ids := make([]string, 0, len(instances))
+	for k := range instances {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+	return ids
+}
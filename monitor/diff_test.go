@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/latam-airlines/mesos-framework-factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDiff(t *testing.T) {
+	suite.Run(t, new(DiffSuite))
+}
+
+type DiffSuite struct {
+	suite.Suite
+	service *framework.ServiceInformation
+}
+
+func (suite *DiffSuite) SetupTest() {
+	suite.service = &framework.ServiceInformation{
+		ID:        "qwerty12345",
+		ImageName: "registry.com/nombre_imagen",
+		ImageTag:  "tag-123",
+		Instances: []*framework.Instance{
+			{ID: "instance1", Host: "thor1", Status: framework.InstanceUp},
+			{ID: "instance2", Host: "thor2", Status: framework.InstanceUp},
+		},
+	}
+}
+
+func (suite *DiffSuite) TestAddedAndRemoved() {
+	assert := assert.New(suite.T())
+	assert.Equal([]ServiceChange{
+		{Type: InstanceAdded, InstanceID: "instance1", Current: "thor1"},
+		{Type: InstanceAdded, InstanceID: "instance2", Current: "thor2"},
+	}, diffServices(nil, suite.service))
+	assert.Equal([]ServiceChange{
+		{Type: InstanceRemoved, InstanceID: "instance1", Previous: "thor1"},
+		{Type: InstanceRemoved, InstanceID: "instance2", Previous: "thor2"},
+	}, diffServices(suite.service, nil))
+}
+
+func (suite *DiffSuite) TestWithoutChanges() {
+	assert.Len(suite.T(), diffServices(suite.service, suite.service), 0)
+}
+
+func (suite *DiffSuite) TestChanges() {
+	assert := assert.New(suite.T())
+	current := &framework.ServiceInformation{
+		ID:        "qwerty12345",
+		ImageName: "registry.com/nombre_imagen",
+		ImageTag:  "tag-124",
+		Instances: []*framework.Instance{
+			{ID: "instance1", Host: "thor3", Status: framework.InstanceDown},
+			{ID: "instance3", Host: "thor2", Status: framework.InstanceUp},
+		},
+	}
+
+	assert.Equal([]ServiceChange{
+		{Type: ImageTagChanged, Previous: "tag-123", Current: "tag-124"},
+		{Type: InstanceRemoved, InstanceID: "instance2", Previous: "thor2"},
+		{Type: HostChanged, InstanceID: "instance1", Previous: "thor1", Current: "thor3"},
+		{Type: HealthChanged, InstanceID: "instance1", Previous: "true", Current: "false"},
+		{Type: InstanceAdded, InstanceID: "instance3", Current: "thor2"},
+	}, diffServices(suite.service, current))
+}
+
+func (suite *DiffSuite) TestChangeTypeString() {
+	assert := assert.New(suite.T())
+	assert.Equal("ImageTagChanged", ImageTagChanged.String())
+	assert.Equal("HostChanged", HostChanged.String())
+	assert.Equal("", ChangeType(0).String())
+}
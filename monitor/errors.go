@@ -0,0 +1,23 @@
+package monitor
+
+import "fmt"
+
+// UnknownServiceDataStatus sucede cuando se intenta obtener un ServiceDataStatus a partir de un nombre invalido
+type UnknownServiceDataStatus struct {
+	Name string
+}
+
+func (err UnknownServiceDataStatus) Error() string {
+	return fmt.Sprintf("El estado de servicio no existe: %s", err.Name)
+}
+
+// CriteriaExpressionError sucede cuando una expresion de criterios no se puede interpretar
+type CriteriaExpressionError struct {
+	Expression string
+	Position   int
+	Message    string
+}
+
+func (err CriteriaExpressionError) Error() string {
+	return fmt.Sprintf("Expresion de criterios invalida en la posicion %d de '%s': %s", err.Position, err.Expression, err.Message)
+}
@@ -0,0 +1,393 @@
+package monitor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseCriteria construye un ServiceChangeCriteria a partir de una expresion, por ejemplo:
+//
+//	image =~ "^registry/app" && cluster in (wdc,dal) && status == removed
+//
+// Los campos disponibles son id, image, tag, cluster, host, status y flapping. El campo host
+// se cumple si alguna de las instancias del servicio esta en un host que cumple la comparacion.
+// Los campos label.<nombre> y env.<nombre> retornan un error porque el scheduler no los informa.
+// Los operadores de comparacion son ==, !=, =~ (regexp), !~ (regexp negada) e in (lista).
+// Las comparaciones se combinan con && y ||, donde && tiene mayor precedencia, se niegan con !
+// y se pueden agrupar con parentesis. Los valores pueden ir entre comillas dobles o sin ellas
+func ParseCriteria(expression string) (ServiceChangeCriteria, error) {
+	p := &expressionParser{expression: expression}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+
+	criteria, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorAt(t, "se esperaba el fin de la expresion")
+	}
+	return criteria, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenValue
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+type expressionParser struct {
+	expression string
+	tokens     []token
+	current    int
+}
+
+var expressionOperators = []string{"&&", "||", "==", "!=", "=~", "!~", "!", "(", ")", ","}
+
+func isValueRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-./:", r)
+}
+
+func (p *expressionParser) tokenize() error {
+	runes := []rune(p.expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return &CriteriaExpressionError{Expression: p.expression, Position: i, Message: "string sin cerrar"}
+			}
+			text := string(runes[i : end+1])
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return &CriteriaExpressionError{Expression: p.expression, Position: i, Message: "string invalido"}
+			}
+			p.tokens = append(p.tokens, token{kind: tokenString, text: text, value: value, pos: i})
+			i = end + 1
+		case isValueRune(r):
+			end := i
+			for end < len(runes) && isValueRune(runes[end]) {
+				end++
+			}
+			text := string(runes[i:end])
+			p.tokens = append(p.tokens, token{kind: tokenValue, text: text, value: text, pos: i})
+			i = end
+		default:
+			var op string
+			for _, candidate := range expressionOperators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return &CriteriaExpressionError{Expression: p.expression, Position: i, Message: "caracter inesperado " + strconv.QuoteRune(r)}
+			}
+			p.tokens = append(p.tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len([]rune(op))
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokenEOF, pos: len(runes)})
+	return nil
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.current]
+}
+
+func (p *expressionParser) next() token {
+	t := p.tokens[p.current]
+	if t.kind != tokenEOF {
+		p.current++
+	}
+	return t
+}
+
+func (p *expressionParser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOperator && t.text == op
+}
+
+func (p *expressionParser) expect(op string) error {
+	if !p.isOperator(op) {
+		return p.errorAt(p.peek(), "se esperaba "+op)
+	}
+	p.next()
+	return nil
+}
+
+func (p *expressionParser) errorAt(t token, message string) error {
+	return &CriteriaExpressionError{Expression: p.expression, Position: t.pos, Message: message}
+}
+
+func (p *expressionParser) parseOr() (ServiceChangeCriteria, error) {
+	criteria, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		p.next()
+		other, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		criteria = NewOrCriteria(criteria, other)
+	}
+	return criteria, nil
+}
+
+func (p *expressionParser) parseAnd() (ServiceChangeCriteria, error) {
+	criteria, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		p.next()
+		other, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		criteria = NewAndCriteria(criteria, other)
+	}
+	return criteria, nil
+}
+
+func (p *expressionParser) parsePrimary() (ServiceChangeCriteria, error) {
+	if p.isOperator("!") {
+		p.next()
+		criteria, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotCriteria{Criteria: criteria}, nil
+	}
+
+	if p.isOperator("(") {
+		p.next()
+		criteria, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return criteria, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *expressionParser) parseComparison() (ServiceChangeCriteria, error) {
+	fieldToken := p.next()
+	if fieldToken.kind != tokenValue {
+		return nil, p.errorAt(fieldToken, "se esperaba un campo")
+	}
+	if prefix := strings.SplitN(fieldToken.value, ".", 2)[0]; prefix == "label" || prefix == "env" {
+		// framework.ServiceInformation no expone labels ni variables de entorno de los schedulers
+		return nil, p.errorAt(fieldToken, "el scheduler no informa "+prefix+" de los servicios")
+	}
+	field, ok := expressionFields[fieldToken.value]
+	if !ok && fieldToken.value != "host" {
+		return nil, p.errorAt(fieldToken, "campo desconocido "+fieldToken.value)
+	}
+
+	opToken := p.next()
+	op := opToken.text
+	if opToken.kind == tokenValue && opToken.value == "in" {
+		op = "in"
+	} else if opToken.kind != tokenOperator || (op != "==" && op != "!=" && op != "=~" && op != "!~") {
+		return nil, p.errorAt(opToken, "se esperaba un operador de comparacion")
+	}
+
+	var values []token
+	if op == "in" {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if !p.isOperator(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	} else {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return p.buildComparison(fieldToken, field, op, values)
+}
+
+func (p *expressionParser) parseValue() (token, error) {
+	t := p.next()
+	if t.kind != tokenValue && t.kind != tokenString {
+		return t, p.errorAt(t, "se esperaba un valor")
+	}
+	return t, nil
+}
+
+func (p *expressionParser) buildComparison(fieldToken token, field expressionField, op string, values []token) (ServiceChangeCriteria, error) {
+	if fieldToken.value == "host" {
+		return p.buildHostComparison(op, values)
+	}
+
+	if op == "=~" || op == "!~" {
+		if field.normalize != nil {
+			return nil, p.errorAt(fieldToken, "el campo "+fieldToken.value+" no admite expresiones regulares")
+		}
+		re, err := regexp.Compile(values[0].value)
+		if err != nil {
+			return nil, p.errorAt(values[0], err.Error())
+		}
+		negate := op == "!~"
+		return &expressionCriteria{match: func(id string, v *ServiceUpdaterData) bool {
+			return re.MatchString(field.value(id, v)) != negate
+		}}, nil
+	}
+
+	expected := make(map[string]bool)
+	for _, v := range values {
+		value := v.value
+		if field.normalize != nil {
+			normalized, err := field.normalize(value)
+			if err != nil {
+				return nil, p.errorAt(v, err.Error())
+			}
+			value = normalized
+		}
+		expected[value] = true
+	}
+
+	negate := op == "!="
+	return &expressionCriteria{match: func(id string, v *ServiceUpdaterData) bool {
+		return expected[field.value(id, v)] != negate
+	}}, nil
+}
+
+// buildHostComparison traduce una comparacion sobre el campo host a un HostCriteria
+// Las comparaciones negadas excluyen los servicios con alguna instancia en un host que cumple
+func (p *expressionParser) buildHostComparison(op string, values []token) (ServiceChangeCriteria, error) {
+	var pattern string
+	if op == "=~" || op == "!~" {
+		pattern = values[0].value
+	} else {
+		hosts := make([]string, 0, len(values))
+		for _, v := range values {
+			hosts = append(hosts, regexp.QuoteMeta(v.value))
+		}
+		pattern = "^(" + strings.Join(hosts, "|") + ")$"
+	}
+
+	hostRegexp, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, p.errorAt(values[0], err.Error())
+	}
+
+	var criteria ServiceChangeCriteria = &HostCriteria{HostRegexp: hostRegexp}
+	if op == "!=" || op == "!~" {
+		criteria = &NotCriteria{Criteria: criteria}
+	}
+	return criteria, nil
+}
+
+// expressionField obtiene el valor de un campo de ServiceUpdaterData
+// normalize, si existe, valida y convierte los valores de la expresion al formato de value
+// y los campos que lo definen no admiten expresiones regulares
+type expressionField struct {
+	value     func(id string, v *ServiceUpdaterData) string
+	normalize func(value string) (string, error)
+}
+
+var expressionFields = map[string]expressionField{
+	"id": {
+		value: func(id string, v *ServiceUpdaterData) string { return id },
+	},
+	"image": {
+		value: func(id string, v *ServiceUpdaterData) string {
+			if v.Origin() == nil {
+				return ""
+			}
+			return v.Origin().ImageName
+		},
+	},
+	"tag": {
+		value: func(id string, v *ServiceUpdaterData) string {
+			if v.Origin() == nil {
+				return ""
+			}
+			return v.Origin().ImageTag
+		},
+	},
+	"cluster": {
+		value: func(id string, v *ServiceUpdaterData) string { return v.ClusterID() },
+	},
+	"status": {
+		value: func(id string, v *ServiceUpdaterData) string { return v.LastAction().String() },
+		normalize: func(value string) (string, error) {
+			status, err := ParseServiceDataStatus(value)
+			if err != nil {
+				return "", err
+			}
+			return status.String(), nil
+		},
+	},
+	"flapping": {
+		value: func(id string, v *ServiceUpdaterData) string { return strconv.FormatBool(v.Flapping()) },
+		normalize: func(value string) (string, error) {
+			flapping, err := strconv.ParseBool(value)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatBool(flapping), nil
+		},
+	},
+}
+
+// expressionCriteria es el criterio resultante de una comparacion de ParseCriteria
+type expressionCriteria struct {
+	match func(id string, v *ServiceUpdaterData) bool
+}
+
+// MeetCriteria retorna los servicios que cumplen con la comparacion
+func (c *expressionCriteria) MeetCriteria(elements map[string]*ServiceUpdaterData) map[string]*ServiceUpdaterData {
+	filtered := make(map[string]*ServiceUpdaterData)
+	for k, v := range elements {
+		if c.match(k, v) {
+			filtered[k] = elements[k]
+		}
+	}
+	return filtered
+}
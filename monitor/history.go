@@ -0,0 +1,91 @@
+package monitor
+
+import "time"
+
+// HistoryEntry registra una transicion de estado de un servicio y los cambios que la provocaron
+type HistoryEntry struct {
+	Date           time.Time         `json:"date"`
+	ClusterID      string            `json:"cluster_id"`
+	PreviousStatus ServiceDataStatus `json:"previous_status,omitempty"`
+	Status         ServiceDataStatus `json:"status"`
+	ImageTag       string            `json:"image_tag,omitempty"`
+	Changes        []ServiceChange   `json:"changes,omitempty"`
+}
+
+// serviceHistory es un buffer circular con las ultimas transiciones de un servicio
+type serviceHistory struct {
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+func newServiceHistory(size int) *serviceHistory {
+	return &serviceHistory{entries: make([]HistoryEntry, size)}
+}
+
+// add agrega una entrada, sobreescribiendo la mas antigua si el buffer esta lleno
+func (h *serviceHistory) add(entry HistoryEntry) {
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// between retorna en orden cronologico las entradas cuya fecha esta entre from y to
+// Una fecha cero no limita el rango
+func (h *serviceHistory) between(from time.Time, to time.Time) []HistoryEntry {
+	ordered := h.entries[:h.next]
+	if h.full {
+		ordered = append(append([]HistoryEntry{}, h.entries[h.next:]...), h.entries[:h.next]...)
+	}
+
+	entries := make([]HistoryEntry, 0, len(ordered))
+	for _, v := range ordered {
+		if !from.IsZero() && v.Date.Before(from) {
+			continue
+		}
+		if !to.IsZero() && v.Date.After(to) {
+			continue
+		}
+		entries = append(entries, v)
+	}
+	return entries
+}
+
+// recordHistory agrega al historial de cada servicio su ultima transicion
+// Se debe llamar con updateServicesMux tomado
+func (su *ServiceUpdater) recordHistory(updatedServices map[string]*ServiceUpdaterData) {
+	for k, v := range updatedServices {
+		h, ok := su.history[k]
+		if !ok {
+			h = newServiceHistory(su.historySize)
+			su.history[k] = h
+		}
+
+		entry := HistoryEntry{
+			Date:           v.lastUpdate,
+			ClusterID:      v.clusterID,
+			PreviousStatus: v.previousAction,
+			Status:         v.lastAction,
+			Changes:        v.changes,
+		}
+		if v.origin != nil {
+			entry.ImageTag = v.origin.ImageTag
+		}
+		h.add(entry)
+	}
+}
+
+// History retorna las transiciones registradas de un servicio entre from y to
+// Retorna false si el servicio no tiene historial
+func (su *ServiceUpdater) History(serviceID string, from time.Time, to time.Time) ([]HistoryEntry, bool) {
+	su.updateServicesMux.Lock()
+	defer su.updateServicesMux.Unlock()
+
+	h, ok := su.history[serviceID]
+	if !ok {
+		return nil, false
+	}
+	return h.between(from, to), true
+}
@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestHistory(t *testing.T) {
+	suite.Run(t, new(HistorySuite))
+}
+
+type HistorySuite struct {
+	suite.Suite
+	date time.Time
+}
+
+func (suite *HistorySuite) SetupTest() {
+	suite.date, _ = time.Parse(time.RFC3339, "2012-11-01T22:08:41+00:00")
+}
+
+func (suite *HistorySuite) entry(minutes int) HistoryEntry {
+	return HistoryEntry{
+		Date:      suite.date.Add(time.Duration(minutes) * time.Minute),
+		ClusterID: "wdc",
+		Status:    ServiceUpdated,
+	}
+}
+
+func (suite *HistorySuite) TestBeforeFull() {
+	assert := assert.New(suite.T())
+	h := newServiceHistory(3)
+	h.add(suite.entry(0))
+	h.add(suite.entry(1))
+	assert.Equal([]HistoryEntry{suite.entry(0), suite.entry(1)}, h.between(time.Time{}, time.Time{}))
+}
+
+func (suite *HistorySuite) TestOverwritesOldest() {
+	assert := assert.New(suite.T())
+	h := newServiceHistory(3)
+	for i := 0; i < 5; i++ {
+		h.add(suite.entry(i))
+	}
+	assert.Equal([]HistoryEntry{suite.entry(2), suite.entry(3), suite.entry(4)}, h.between(time.Time{}, time.Time{}))
+}
+
+func (suite *HistorySuite) TestBetween() {
+	assert := assert.New(suite.T())
+	h := newServiceHistory(10)
+	for i := 0; i < 5; i++ {
+		h.add(suite.entry(i))
+	}
+	from := suite.date.Add(time.Minute)
+	to := suite.date.Add(3 * time.Minute)
+	assert.Equal([]HistoryEntry{suite.entry(1), suite.entry(2), suite.entry(3)}, h.between(from, to))
+	assert.Equal([]HistoryEntry{suite.entry(3), suite.entry(4)}, h.between(to, time.Time{}))
+	assert.Len(h.between(suite.date.Add(time.Hour), time.Time{}), 0)
+}
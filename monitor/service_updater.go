@@ -1,11 +1,12 @@
 package monitor
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/ch3lo/overlord/cluster"
 	"github.com/ch3lo/overlord/configuration"
 	"github.com/ch3lo/overlord/logger"
@@ -25,6 +26,8 @@ const (
 	ServiceRemoved
 	// ServiceUpdating estado de un servicio que se encuentra en estado de actualizacion
 	ServiceUpdating
+	// ServicePurged estado final de un servicio removido que se elimina de la memoria del updater
+	ServicePurged
 )
 
 var statuses = [...]string{
@@ -32,20 +35,42 @@ var statuses = [...]string{
 	"ServiceAdded",
 	"ServiceRemoved",
 	"ServiceUpdating",
+	"ServicePurged",
 }
 
 func (s ServiceDataStatus) String() string {
+	if s < ServiceUpdated || int(s) > len(statuses) {
+		return ""
+	}
 	return statuses[s-1]
 }
 
+// ParseServiceDataStatus obtiene un ServiceDataStatus a partir de su nombre
+// Acepta el nombre completo (ServiceRemoved) o abreviado (removed) sin importar mayusculas
+func ParseServiceDataStatus(name string) (ServiceDataStatus, error) {
+	for k, v := range statuses {
+		if strings.EqualFold(name, v) || strings.EqualFold("Service"+name, v) {
+			return ServiceDataStatus(k + 1), nil
+		}
+	}
+	return 0, &UnknownServiceDataStatus{Name: name}
+}
+
 // ServiceUpdaterData basicamente es un decorador de scheduler.ServiceInformation
 // que busca encapsular esta informacion y agregarle metadata de su estado de actualizacion
 type ServiceUpdaterData struct {
-	registerDate time.Time
-	lastUpdate   time.Time
-	lastAction   ServiceDataStatus
-	clusterID    string
-	origin       *framework.ServiceInformation
+	registerDate   time.Time
+	lastUpdate     time.Time
+	lastAction     ServiceDataStatus
+	previousAction ServiceDataStatus
+	clusterID      string
+	origin         *framework.ServiceInformation
+	previousOrigin *framework.ServiceInformation
+	changes        []ServiceChange
+	missedPolls    int
+	firstMissed    time.Time
+	disappearances []time.Time
+	flapping       bool
 }
 
 // NewServiceUpdaterData crea una nueva instancia de ServiceUpdaterData
@@ -67,12 +92,28 @@ func (data *ServiceUpdaterData) LastUpdate() time.Time { return data.lastUpdate
 // LastAction obtiene la ultima acción que se ejecuto sobre esta instancia
 func (data *ServiceUpdaterData) LastAction() ServiceDataStatus { return data.lastAction }
 
+// PreviousAction obtiene la acción que tenia esta instancia antes de la ultima actualizacion
+// Es cero si el servicio recien fue agregado
+func (data *ServiceUpdaterData) PreviousAction() ServiceDataStatus { return data.previousAction }
+
 // ClusterID obtiene el identificador del cluster al cual pertenece esta instancia
 func (data *ServiceUpdaterData) ClusterID() string { return data.clusterID }
 
 // Origin es un wrapper a la información obtenida desde el scheduler
 func (data *ServiceUpdaterData) Origin() *framework.ServiceInformation { return data.origin }
 
+// PreviousOrigin es la información obtenida desde el scheduler antes de la ultima actualizacion
+// Es nil si el servicio recien fue agregado
+func (data *ServiceUpdaterData) PreviousOrigin() *framework.ServiceInformation {
+	return data.previousOrigin
+}
+
+// Changes retorna los cambios detectados en la ultima actualizacion de esta instancia
+func (data *ServiceUpdaterData) Changes() []ServiceChange { return data.changes }
+
+// Flapping retorna true si el servicio desaparecio repetidamente de su scheduler en la ventana de tiempo configurada
+func (data *ServiceUpdaterData) Flapping() bool { return data.flapping }
+
 // InStatus retorna un bool tru si la instancia se encuentra en el estado pasado como parametro
 func (data *ServiceUpdaterData) InStatus(status ServiceDataStatus) bool {
 	return data.lastAction == status
@@ -82,13 +123,29 @@ func (data *ServiceUpdaterData) InStatus(status ServiceDataStatus) bool {
 // Acepta que se registren observers con un criterio de filtro.
 // Para que estos observers sean notificados cuando se cumple con el criterio entregado
 type ServiceUpdater struct {
+	ctx                context.Context
+	cancel             context.CancelFunc
+	running            sync.WaitGroup
 	updateServicesMux  sync.Mutex
 	subscriberMux      sync.Mutex
 	interval           time.Duration
+	snapshotFile       string
+	snapshotInterval   time.Duration
 	subscribers        map[string]ServiceUpdaterSubscriber
 	subscriberCriteria map[string]ServiceChangeCriteria
 	clusters           map[string]*cluster.Cluster
+	pollers            map[string]*clusterPoller
 	services           map[string]*ServiceUpdaterData
+	historySize        int
+	history            map[string]*serviceHistory
+	missedPolls        int
+	removeGracePeriod  time.Duration
+	flapWindow         time.Duration
+	flapThreshold      int
+	removedRetention   time.Duration
+	maxRemoved         int
+	sweepInterval      time.Duration
+	purged             int
 }
 
 // NewServiceUpdater crea una nueva instancia de ServiceUpdater
@@ -103,21 +160,116 @@ func NewServiceUpdater(config configuration.Updater, clusters map[string]*cluste
 		interval = config.Interval
 	}
 
+	snapshotInterval := time.Minute
+	if config.SnapshotInterval != 0 {
+		snapshotInterval = config.SnapshotInterval
+	}
+
+	historySize := 50
+	if config.HistorySize > 0 {
+		historySize = config.HistorySize
+	} else if config.HistorySize < 0 {
+		logger.Instance().Warnf("historySize %d invalido, se guardaran %d transiciones por servicio", config.HistorySize, historySize)
+	}
+
+	missedPolls := 1
+	if config.MissedPollsBeforeRemove != 0 {
+		missedPolls = config.MissedPollsBeforeRemove
+	}
+
+	flapWindow := time.Minute * 10
+	if config.FlapWindow != 0 {
+		flapWindow = config.FlapWindow
+	}
+
+	flapThreshold := 3
+	if config.FlapThreshold != 0 {
+		flapThreshold = config.FlapThreshold
+	}
+
+	removedRetention := time.Hour
+	if config.RemovedRetention != 0 {
+		removedRetention = config.RemovedRetention
+	}
+
+	maxRemoved := 1000
+	if config.MaxRemoved != 0 {
+		maxRemoved = config.MaxRemoved
+	}
+
+	sweepInterval := time.Minute
+	if config.SweepInterval != 0 {
+		sweepInterval = config.SweepInterval
+	}
+
 	s := &ServiceUpdater{
 		interval:           interval,
+		snapshotFile:       config.SnapshotFile,
+		snapshotInterval:   snapshotInterval,
 		subscribers:        make(map[string]ServiceUpdaterSubscriber),
 		subscriberCriteria: make(map[string]ServiceChangeCriteria),
 		services:           make(map[string]*ServiceUpdaterData),
+		historySize:        historySize,
+		history:            make(map[string]*serviceHistory),
+		missedPolls:        missedPolls,
+		removeGracePeriod:  config.RemoveGracePeriod,
+		flapWindow:         flapWindow,
+		flapThreshold:      flapThreshold,
+		removedRetention:   removedRetention,
+		maxRemoved:         maxRemoved,
+		sweepInterval:      sweepInterval,
 	}
 	s.clusters = clusters
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	timeout := time.Second * 30
+	if config.Timeout != 0 {
+		timeout = config.Timeout
+	}
+
+	s.pollers = make(map[string]*clusterPoller)
+	for k := range clusters {
+		poller := newClusterPoller(k, clusters[k], interval, timeout)
+		if clusterConfig, ok := config.Clusters[k]; ok {
+			if clusterConfig.Interval != 0 {
+				poller.interval = clusterConfig.Interval
+			}
+			if clusterConfig.Timeout != 0 {
+				poller.timeout = clusterConfig.Timeout
+			}
+		}
+		s.pollers[k] = poller
+	}
+
+	if s.snapshotFile != "" {
+		if err := s.loadSnapshot(); err != nil {
+			logger.Instance().Warnf("No se pudo restaurar el estado de los servicios desde %s: %s", s.snapshotFile, err.Error())
+		}
+	}
 
 	return s
 }
 
 // Register registra un nuevo observer/subscriptor con un criterio de filtro
 // Cada vez que se obtiene informacion de los schedulers se le notificara al subsriptor de regreso
-// con los servicios actualizados
+// con los servicios actualizados. Al registrarse se le notifican los servicios conocidos que
+// cumplen con el criterio
 func (su *ServiceUpdater) Register(sub ServiceUpdaterSubscriber, cc ServiceChangeCriteria) {
+	su.register(sub, cc, true)
+}
+
+// Watch registra un subscriptor que solo recibe los cambios detectados luego de registrarse,
+// sin la notificacion inicial de los servicios conocidos
+func (su *ServiceUpdater) Watch(sub ServiceUpdaterSubscriber, cc ServiceChangeCriteria) {
+	su.register(sub, cc, false)
+}
+
+// register agrega el subscriptor y si replay es true le notifica los servicios conocidos
+// Se toma updateServicesMux antes que subscriberMux, al igual que en las notificaciones,
+// para que el subscriptor lea los servicios sin competir con el monitoreo ni con la limpieza
+func (su *ServiceUpdater) register(sub ServiceUpdaterSubscriber, cc ServiceChangeCriteria, replay bool) {
+	su.updateServicesMux.Lock()
+	defer su.updateServicesMux.Unlock()
 	su.subscriberMux.Lock()
 	defer su.subscriberMux.Unlock()
 
@@ -130,6 +282,10 @@ func (su *ServiceUpdater) Register(sub ServiceUpdaterSubscriber, cc ServiceChang
 
 	logger.Instance().Infof("Se agregó el subscriptor: %s", sub.ID())
 
+	if !replay {
+		return
+	}
+
 	filtered := cc.MeetCriteria(su.services)
 	if filtered != nil && len(filtered) > 0 {
 		sub.Update(filtered)
@@ -152,6 +308,7 @@ func (su *ServiceUpdater) Remove(sub ServiceUpdaterSubscriber) {
 
 // Notify aplica los filtros de criterio sobre los updatedServices y notifica a los subscriptores
 // si despues de aplicar el filtro existen resultados
+// Si updatedServices son los servicios del updater se debe llamar con updateServicesMux tomado
 func (su *ServiceUpdater) notify(updatedServices map[string]*ServiceUpdaterData) {
 	su.subscriberMux.Lock()
 	defer su.subscriberMux.Unlock()
@@ -166,49 +323,132 @@ func (su *ServiceUpdater) notify(updatedServices map[string]*ServiceUpdaterData)
 }
 
 // Monitor comienza el monitoreo de los servicios de manera desatachada
+// Si esta configurado un archivo de respaldo, tambien se respalda periodicamente el estado
 func (su *ServiceUpdater) Monitor() {
-	go su.detachedMonitor()
+	results := make(chan clusterPollResult)
+	su.running.Add(2 + len(su.pollers))
+	for _, p := range su.pollers {
+		go su.detachedPoller(p, results)
+	}
+	go su.detachedMonitor(results)
+	go su.detachedSweeper()
+	if su.snapshotFile != "" {
+		su.running.Add(1)
+		go su.detachedSnapshot()
+	}
 }
 
-// detachedMonitor loop que permite monitorear los servicios de los schedulers
-func (su *ServiceUpdater) detachedMonitor() {
+// Stop detiene el monitoreo y espera a que termine la iteracion en curso
+// Si esta configurado un archivo de respaldo se respalda por ultima vez el estado
+func (su *ServiceUpdater) Stop() {
+	logger.Instance().Infoln("Deteniendo el monitoreo de servicios")
+	su.cancel()
+	su.running.Wait()
+
+	if su.snapshotFile != "" {
+		if err := su.saveSnapshot(); err != nil {
+			logger.Instance().Errorf("No se pudo respaldar el estado de los servicios en %s: %s", su.snapshotFile, err.Error())
+		}
+	}
+	logger.Instance().Infoln("Monitoreo de servicios detenido")
+}
+
+// detachedMonitor loop que integra los resultados de los clusters por rondas
+// Una ronda termina cuando todos los clusters respondieron o cuando se cumple el intervalo del
+// updater, por lo que un scheduler lento no retrasa los cambios de los demas clusters. Los
+// resultados de la ronda se integran en un solo paso y se notifican de una sola vez
+func (su *ServiceUpdater) detachedMonitor(results <-chan clusterPollResult) {
+	defer su.running.Done()
+
+	ticker := time.NewTicker(su.interval)
+	defer ticker.Stop()
+
+	var round []clusterPollResult
+	reported := make(map[string]bool)
 	for {
-		updatedServices := make(map[string]*ServiceUpdaterData)
-
-		su.updateServicesMux.Lock()
-
-		for clusterKey, c := range su.clusters {
-			logger.Instance().WithField("cluster", clusterKey).Infof("Monitoreando cluster")
-			srvs, err := c.GetScheduler().FindServiceInformation(nil)
-			if err != nil {
-				logger.Instance().WithFields(log.Fields{
-					"cluster":   clusterKey,
-					"scheduler": c.GetScheduler().ID(),
-				}).Errorf("No se pudieron obtener instancias del cluster. Motivo: %s", err.Error())
+		select {
+		case <-su.ctx.Done():
+			return
+		case result := <-results:
+			round = append(round, result)
+			reported[result.clusterID] = true
+			if len(reported) < len(su.pollers) {
 				continue
 			}
-			checkedServices := su.checkClusterServices(clusterKey, srvs)
-			for k := range checkedServices {
-				updatedServices[k] = checkedServices[k]
+		case <-ticker.C:
+			if len(round) == 0 {
+				continue
 			}
-			logger.Instance().WithField("cluster", clusterKey).Infof("Se actualizaron %d servicios", len(checkedServices))
 		}
 
-		su.updateServicesMux.Unlock()
+		su.updateClusters(round)
+		round = nil
+		reported = make(map[string]bool)
+	}
+}
+
+// detachedPoller consulta periodicamente el scheduler de un cluster y envia los resultados
+// a detachedMonitor. Las consultas se agendan desde su inicio, por lo que la duracion de
+// una consulta no desplaza las siguientes
+func (su *ServiceUpdater) detachedPoller(p *clusterPoller, results chan<- clusterPollResult) {
+	defer su.running.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
 
-		logger.Instance().Infof("Se actualizaron %d servicios", len(updatedServices))
+	for {
+		logger.Instance().WithField("cluster", p.id).Infof("Monitoreando cluster")
+		services, err := p.poll(su.ctx)
 
-		if updatedServices != nil && len(updatedServices) > 0 {
-			su.notify(updatedServices)
+		select {
+		case <-su.ctx.Done():
+			return
+		case results <- clusterPollResult{clusterID: p.id, services: services, err: err}:
 		}
 
-		time.Sleep(su.interval)
+		select {
+		case <-su.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateClusters integra los resultados de una ronda y notifica los cambios en un solo paso
+// Los resultados se aplican en el orden en que llegaron, por lo que si un cluster respondio
+// mas de una vez en la ronda prevalece su ultimo estado
+func (su *ServiceUpdater) updateClusters(results []clusterPollResult) {
+	su.updateServicesMux.Lock()
+	defer su.updateServicesMux.Unlock()
+
+	updatedServices := make(map[string]*ServiceUpdaterData)
+	for _, result := range results {
+		if result.err != nil {
+			logger.Instance().WithField("cluster", result.clusterID).Errorf("No se pudieron obtener instancias del cluster. Motivo: %s", result.err.Error())
+			continue
+		}
+
+		updated := su.checkClusterServices(result.clusterID, result.services)
+		su.recordHistory(updated)
+		for k, v := range updated {
+			updatedServices[k] = v
+		}
+
+		logger.Instance().WithField("cluster", result.clusterID).Infof("Se actualizaron %d servicios", len(updated))
+	}
+
+	// Se notifica con updateServicesMux tomado ya que los subscriptores leen los mismos
+	// ServiceUpdaterData que modifica la siguiente ronda
+	if len(updatedServices) > 0 {
+		su.notify(updatedServices)
 	}
 }
 
 func (su *ServiceUpdater) checkClusterServices(clusterID string, clusterServices []*framework.ServiceInformation) map[string]*ServiceUpdaterData {
 	updatedServices := make(map[string]*ServiceUpdaterData)
 
+	now := time.Now()
+
 	// Se asume por defecto que un servicio esta actualizandose
 	// Luego se actualiza al estado correcto
 	// Si el servicio ya fue removido no se toma en cuenta
@@ -216,6 +456,8 @@ func (su *ServiceUpdater) checkClusterServices(clusterID string, clusterServices
 	// pero se interpretara como un servicio nuevo
 	for k := range su.services {
 		if su.services[k].clusterID == clusterID && su.services[k].lastAction != ServiceRemoved {
+			su.services[k].previousAction = su.services[k].lastAction
+			su.services[k].previousOrigin = su.services[k].origin
 			su.services[k].lastAction = ServiceUpdating
 			su.services[k].lastUpdate = time.Now()
 			updatedServices[k] = su.services[k]
@@ -227,6 +469,7 @@ func (su *ServiceUpdater) checkClusterServices(clusterID string, clusterServices
 			newService := NewServiceUpdaterData()
 			newService.clusterID = clusterID
 			newService.origin = clusterServices[k]
+			newService.changes = diffServices(nil, clusterServices[k])
 
 			su.services[v.ID] = newService
 			updatedServices[v.ID] = newService
@@ -236,25 +479,83 @@ func (su *ServiceUpdater) checkClusterServices(clusterID string, clusterServices
 		}
 
 		su.services[v.ID].lastUpdate = time.Now()
+		su.services[v.ID].missedPolls = 0
+
+		// Un servicio que vuelve luego de haber sido removido se interpreta como nuevo
+		if su.services[v.ID].lastAction == ServiceRemoved {
+			su.services[v.ID].previousAction = ServiceRemoved
+			su.services[v.ID].previousOrigin = su.services[v.ID].origin
+			su.services[v.ID].lastAction = ServiceAdded
+			su.services[v.ID].origin = clusterServices[k]
+			su.services[v.ID].changes = diffServices(nil, clusterServices[k])
+			updatedServices[v.ID] = su.services[v.ID]
+			logger.Instance().WithField("cluster", clusterID).Debugf("Servicio removido volvio a aparecer %+v", clusterServices[k])
+			continue
+		}
+
 		su.services[v.ID].lastAction = ServiceUpdated
 
 		if reflect.DeepEqual(su.services[v.ID].origin, clusterServices[k]) {
+			su.services[v.ID].changes = nil
 			delete(updatedServices, v.ID)
 			logger.Instance().WithField("cluster", clusterID).Debugf("Servicio sin cambios %+v", clusterServices[k])
 			continue
 		}
 
+		su.services[v.ID].changes = diffServices(su.services[v.ID].origin, clusterServices[k])
 		su.services[v.ID].origin = clusterServices[k]
-		logger.Instance().WithField("cluster", clusterID).Debugf("Servicio tuvo un cambio %+v <-> %+v", su.services[v.ID].Origin(), clusterServices[k])
+		logger.Instance().WithField("cluster", clusterID).Debugf("Servicio tuvo un cambio %+v", su.services[v.ID].changes)
 	}
 
 	for k := range su.services {
 		if su.services[k].lastAction == ServiceUpdating {
+			su.services[k].missedPolls++
+			if su.services[k].missedPolls == 1 {
+				su.services[k].firstMissed = now
+				su.services[k].disappearances = append(su.services[k].disappearances, now)
+			}
+
+			// Se espera a confirmar la remocion para no interpretar un fallo puntual del scheduler como una baja
+			if su.services[k].missedPolls < su.missedPolls || now.Sub(su.services[k].firstMissed) < su.removeGracePeriod {
+				logger.Instance().WithField("cluster", clusterID).Debugf("Servicio no encontrado %d veces, aun no se confirma su remocion %+v", su.services[k].missedPolls, su.services[k])
+				su.services[k].lastAction = su.services[k].previousAction
+				delete(updatedServices, k)
+				continue
+			}
+
 			logger.Instance().WithField("cluster", clusterID).Debugf("Servicio removido %+v", su.services[k])
 			su.services[k].lastAction = ServiceRemoved
+			su.services[k].changes = diffServices(su.services[k].origin, nil)
 			su.services[k].lastUpdate = time.Now()
 		}
 	}
 
+	su.checkFlapping(clusterID, now, updatedServices)
+
 	return updatedServices
 }
+
+// checkFlapping marca como intermitentes los servicios del cluster que desaparecieron al menos
+// flapThreshold veces dentro de flapWindow. Los servicios que cambian de estado se agregan a updatedServices
+func (su *ServiceUpdater) checkFlapping(clusterID string, now time.Time, updatedServices map[string]*ServiceUpdaterData) {
+	for k, v := range su.services {
+		if v.clusterID != clusterID {
+			continue
+		}
+
+		var recent []time.Time
+		for _, d := range v.disappearances {
+			if now.Sub(d) <= su.flapWindow {
+				recent = append(recent, d)
+			}
+		}
+		v.disappearances = recent
+
+		flapping := len(recent) >= su.flapThreshold
+		if flapping != v.flapping {
+			logger.Instance().WithField("cluster", clusterID).Infof("Servicio %s intermitente: %t", k, flapping)
+			v.flapping = flapping
+			updatedServices[k] = v
+		}
+	}
+}
@@ -2,12 +2,17 @@ package monitor
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ch3lo/overlord/cluster"
 	"github.com/ch3lo/overlord/configuration"
-	"github.com/ch3lo/overlord/scheduler"
+	"github.com/ch3lo/overlord/logger"
+	"github.com/latam-airlines/mesos-framework-factory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
@@ -28,6 +33,20 @@ func (suite *ServiceDataStatusSuite) TestAreDistinct() {
 	assert.Equal(fmt.Sprint(ServiceAdded), "ServiceAdded")
 	assert.Equal(fmt.Sprint(ServiceRemoved), "ServiceRemoved")
 	assert.Equal(fmt.Sprint(ServiceUpdating), "ServiceUpdating")
+	assert.Equal(fmt.Sprint(ServicePurged), "ServicePurged")
+}
+
+func (suite *ServiceDataStatusSuite) TestParse() {
+	assert := assert.New(suite.T())
+	status, err := ParseServiceDataStatus("ServiceRemoved")
+	assert.Nil(err)
+	assert.Equal(ServiceRemoved, status)
+	status, err = ParseServiceDataStatus("added")
+	assert.Nil(err)
+	assert.Equal(ServiceAdded, status)
+	_, err = ParseServiceDataStatus("borrado")
+	assert.IsType(new(UnknownServiceDataStatus), err)
+	assert.Equal("", fmt.Sprint(ServiceDataStatus(0)))
 }
 
 type ServiceUpdaterDataSuite struct {
@@ -40,7 +59,7 @@ func (suite *ServiceUpdaterDataSuite) TestNew() {
 	assert.NotNil(updater.RegisterDate())
 	assert.Equal(updater.LastAction(), ServiceAdded)
 	assert.Equal(updater.ClusterID(), "")
-	assert.Equal(updater.Origin(), scheduler.ServiceInformation{})
+	assert.Nil(updater.Origin())
 	assert.Equal(updater.InStatus(ServiceAdded), true)
 }
 
@@ -63,4 +82,319 @@ func (suite *ServiceUpdaterSuite) TestNewServiceUpdater() {
 	assert.NotNil(updater.subscriberCriteria)
 	assert.NotNil(updater.services)
 	assert.Equal(c, updater.clusters)
+	assert.Equal(50, updater.historySize)
+
+	updater = NewServiceUpdater(configuration.Updater{HistorySize: -1}, c)
+	assert.Equal(50, updater.historySize)
+	assert.NotPanics(func() { newServiceHistory(updater.historySize).add(HistoryEntry{}) })
+}
+
+func (suite *ServiceUpdaterSuite) TestClusterPollers() {
+	assert := assert.New(suite.T())
+	config := configuration.Updater{
+		Interval: 20 * time.Second,
+		Clusters: map[string]configuration.ClusterUpdater{
+			"dal": {Interval: 5 * time.Second, Timeout: time.Second},
+		},
+	}
+	c := map[string]*cluster.Cluster{"dal": &cluster.Cluster{}, "wdc": &cluster.Cluster{}}
+
+	updater := NewServiceUpdater(config, c)
+	assert.Len(updater.pollers, 2)
+	assert.Equal(5*time.Second, updater.pollers["dal"].interval)
+	assert.Equal(time.Second, updater.pollers["dal"].timeout)
+	assert.Equal(20*time.Second, updater.pollers["wdc"].interval)
+	assert.Equal(30*time.Second, updater.pollers["wdc"].timeout)
+}
+
+type channelSubscriber struct {
+	updates chan map[string]*ServiceUpdaterData
+}
+
+func (s *channelSubscriber) ID() string { return "channel" }
+
+func (s *channelSubscriber) Update(data map[string]*ServiceUpdaterData) {
+	s.updates <- data
+}
+
+func (suite *ServiceUpdaterSuite) TestSlowClusterDoesNotBlock() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	config := configuration.Updater{Interval: 10 * time.Millisecond}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"dal": &cluster.Cluster{}, "wdc": &cluster.Cluster{}})
+
+	release := make(chan bool)
+	defer close(release)
+	updater.pollers["wdc"].find = func() ([]*framework.ServiceInformation, error) {
+		<-release
+		return nil, nil
+	}
+	updater.pollers["dal"].find = func() ([]*framework.ServiceInformation, error) {
+		return []*framework.ServiceInformation{{ID: "qwerty12345"}}, nil
+	}
+
+	sub := &channelSubscriber{updates: make(chan map[string]*ServiceUpdaterData, 1)}
+	updater.Register(sub, &StatusCriteria{ServiceAdded})
+	updater.Monitor()
+
+	select {
+	case updated := <-sub.updates:
+		assert.Equal("dal", updated["qwerty12345"].ClusterID())
+	case <-time.After(time.Second):
+		assert.Fail("El cluster lento retraso la notificacion del cluster dal")
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		updater.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		assert.Fail("El monitoreo no se detuvo mientras el cluster wdc no respondia")
+	}
+}
+
+func (suite *ServiceUpdaterSuite) TestRoundNotifiesOnce() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	// El intervalo no se cumple durante la prueba, la ronda termina cuando responden ambos clusters
+	config := configuration.Updater{Interval: time.Hour}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"dal": &cluster.Cluster{}, "wdc": &cluster.Cluster{}})
+	updater.pollers["dal"].find = func() ([]*framework.ServiceInformation, error) {
+		return []*framework.ServiceInformation{{ID: "srv-dal"}}, nil
+	}
+	updater.pollers["wdc"].find = func() ([]*framework.ServiceInformation, error) {
+		return []*framework.ServiceInformation{{ID: "srv-wdc"}}, nil
+	}
+
+	sub := &channelSubscriber{updates: make(chan map[string]*ServiceUpdaterData, 2)}
+	updater.Register(sub, &StatusCriteria{ServiceAdded})
+	updater.Monitor()
+	defer updater.Stop()
+
+	select {
+	case updated := <-sub.updates:
+		assert.Len(updated, 2)
+		assert.Equal("dal", updated["srv-dal"].ClusterID())
+		assert.Equal("wdc", updated["srv-wdc"].ClusterID())
+	case <-time.After(time.Second):
+		assert.Fail("No se notifico la ronda")
+	}
+}
+
+func (suite *ServiceUpdaterSuite) TestSnapshot() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	dir, err := ioutil.TempDir("", "overlord-snapshot")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	config := configuration.Updater{SnapshotFile: filepath.Join(dir, "updater.json")}
+	c := map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}}
+
+	date, _ := time.Parse(time.RFC3339, "2012-11-01T22:08:41Z")
+	updater := NewServiceUpdater(config, c)
+	updater.services["qwerty12345"] = &ServiceUpdaterData{
+		registerDate: date,
+		lastUpdate:   date,
+		lastAction:   ServiceUpdated,
+		clusterID:    "wdc",
+		origin: &framework.ServiceInformation{
+			ID:        "qwerty12345",
+			ImageName: "registry.com/nombre_imagen",
+			ImageTag:  "tag-123",
+		},
+	}
+	updater.services["asdasd"] = &ServiceUpdaterData{
+		registerDate: date,
+		lastUpdate:   date,
+		lastAction:   ServiceAdded,
+		clusterID:    "dal",
+		origin:       &framework.ServiceInformation{ID: "asdasd"},
+	}
+	updater.recordHistory(updater.services)
+	assert.Nil(updater.saveSnapshot())
+
+	restored := NewServiceUpdater(config, c)
+	assert.Len(restored.services, 1)
+	assert.Equal(updater.services["qwerty12345"], restored.services["qwerty12345"])
+
+	history, ok := restored.History("qwerty12345", time.Time{}, time.Time{})
+	assert.True(ok)
+	assert.Equal([]HistoryEntry{{Date: date, ClusterID: "wdc", Status: ServiceUpdated, ImageTag: "tag-123"}}, history)
+	_, ok = restored.History("asdasd", time.Time{}, time.Time{})
+	assert.False(ok)
+}
+
+func (suite *ServiceUpdaterSuite) TestStopSavesSnapshot() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	dir, err := ioutil.TempDir("", "overlord-snapshot")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	config := configuration.Updater{SnapshotFile: filepath.Join(dir, "updater.json")}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}})
+	updater.Stop()
+
+	_, err = os.Stat(config.SnapshotFile)
+	assert.Nil(err)
+	assert.Error(updater.ctx.Err())
+}
+
+func (suite *ServiceUpdaterSuite) TestRemoveAfterMissedPolls() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	config := configuration.Updater{MissedPollsBeforeRemove: 2, FlapThreshold: 10}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}})
+	srv := &framework.ServiceInformation{ID: "qwerty12345", ImageTag: "tag-123"}
+
+	updated := updater.checkClusterServices("wdc", []*framework.ServiceInformation{srv})
+	assert.Equal(ServiceAdded, updated["qwerty12345"].LastAction())
+
+	updated = updater.checkClusterServices("wdc", nil)
+	assert.Len(updated, 0)
+	assert.Equal(ServiceAdded, updater.services["qwerty12345"].LastAction())
+
+	updated = updater.checkClusterServices("wdc", nil)
+	assert.Equal(ServiceRemoved, updated["qwerty12345"].LastAction())
+
+	updated = updater.checkClusterServices("wdc", []*framework.ServiceInformation{srv})
+	assert.Equal(ServiceAdded, updated["qwerty12345"].LastAction())
+	assert.Equal(ServiceRemoved, updated["qwerty12345"].PreviousAction())
+}
+
+func (suite *ServiceUpdaterSuite) TestFlapping() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	config := configuration.Updater{FlapThreshold: 2}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}})
+	srv := &framework.ServiceInformation{ID: "qwerty12345", ImageTag: "tag-123"}
+
+	updater.checkClusterServices("wdc", []*framework.ServiceInformation{srv})
+	updater.checkClusterServices("wdc", nil)
+	updater.checkClusterServices("wdc", []*framework.ServiceInformation{srv})
+	assert.False(updater.services["qwerty12345"].Flapping())
+
+	updated := updater.checkClusterServices("wdc", nil)
+	assert.True(updated["qwerty12345"].Flapping())
+	assert.Len((&FlappingCriteria{true}).MeetCriteria(updater.services), 1)
+}
+
+type purgeSubscriber struct {
+	purged map[string]*ServiceUpdaterData
+}
+
+func (s *purgeSubscriber) ID() string { return "purge" }
+
+func (s *purgeSubscriber) Update(data map[string]*ServiceUpdaterData) {
+	for k, v := range data {
+		s.purged[k] = v
+	}
+}
+
+func (suite *ServiceUpdaterSuite) TestSweep() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	config := configuration.Updater{RemovedRetention: time.Hour, MaxRemoved: 2}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}})
+
+	now := time.Now()
+	for i, id := range []string{"old", "removed1", "removed2", "removed3", "running"} {
+		data := NewServiceUpdaterData()
+		data.clusterID = "wdc"
+		data.lastAction = ServiceRemoved
+		data.lastUpdate = now.Add(time.Duration(i) * time.Minute)
+		updater.services[id] = data
+	}
+	updater.services["old"].lastUpdate = now.Add(-2 * time.Hour)
+	updater.services["running"].lastAction = ServiceUpdated
+
+	old := updater.services["old"]
+
+	sub := &purgeSubscriber{purged: make(map[string]*ServiceUpdaterData)}
+	updater.Register(sub, &StatusCriteria{ServicePurged})
+	updater.sweep(now)
+
+	assert.Len(sub.purged, 2)
+	assert.NotNil(sub.purged["removed1"])
+	assert.Equal(ServicePurged, sub.purged["old"].LastAction())
+	assert.Equal(ServiceRemoved, sub.purged["old"].PreviousAction())
+	assert.False(sub.purged["old"] == old)
+	assert.Equal(ServiceRemoved, old.LastAction())
+	assert.Equal(UpdaterStats{Services: 3, Removed: 2, Purged: 2}, updater.Stats())
+}
+
+type idSubscriber struct {
+	id      string
+	updates int
+}
+
+func (s *idSubscriber) ID() string { return s.id }
+
+// Update lee los servicios notificados para que el detector de carreras valide que
+// no se modifican mientras el subscriptor los procesa
+func (s *idSubscriber) Update(data map[string]*ServiceUpdaterData) {
+	for _, v := range data {
+		_ = v.LastAction()
+		_ = v.Changes()
+	}
+	s.updates++
+}
+
+func (suite *ServiceUpdaterSuite) TestRegisterDuringPoll() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	config := configuration.Updater{RemovedRetention: time.Nanosecond}
+	updater := NewServiceUpdater(config, map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv := &framework.ServiceInformation{ID: fmt.Sprintf("srv%d", i)}
+			updater.updateClusters([]clusterPollResult{{clusterID: "wdc", services: []*framework.ServiceInformation{srv}}})
+			updater.sweep(time.Now())
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		updater.Register(&idSubscriber{id: fmt.Sprintf("sub%d", i)}, &StatusCriteria{ServiceAdded})
+	}
+	wg.Wait()
+
+	assert.Len(suite.T(), updater.subscribers, 100)
+}
+
+func (suite *ServiceUpdaterSuite) TestWatch() {
+	assert := assert.New(suite.T())
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+
+	updater := NewServiceUpdater(configuration.Updater{}, map[string]*cluster.Cluster{"wdc": &cluster.Cluster{}})
+	srv := &framework.ServiceInformation{ID: "qwerty12345", ImageTag: "v1"}
+	updater.updateClusters([]clusterPollResult{{clusterID: "wdc", services: []*framework.ServiceInformation{srv}}})
+
+	registered := &idSubscriber{id: "registered"}
+	updater.Register(registered, &ClusterCriteria{Clusters: []string{"wdc"}})
+	watcher := &idSubscriber{id: "watcher"}
+	updater.Watch(watcher, &ClusterCriteria{Clusters: []string{"wdc"}})
+
+	assert.Equal(1, registered.updates)
+	assert.Equal(0, watcher.updates)
+
+	srv = &framework.ServiceInformation{ID: "qwerty12345", ImageTag: "v2"}
+	updater.updateClusters([]clusterPollResult{{clusterID: "wdc", services: []*framework.ServiceInformation{srv}}})
+
+	assert.Equal(2, registered.updates)
+	assert.Equal(1, watcher.updates)
 }
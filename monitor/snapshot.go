@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/latam-airlines/mesos-framework-factory"
+)
+
+// serviceUpdaterDataSnapshot es la representacion serializable de ServiceUpdaterData
+// junto al historial de transiciones del servicio en orden cronologico
+type serviceUpdaterDataSnapshot struct {
+	RegisterDate time.Time                     `json:"register_date"`
+	LastUpdate   time.Time                     `json:"last_update"`
+	LastAction   ServiceDataStatus             `json:"last_action"`
+	ClusterID    string                        `json:"cluster_id"`
+	Origin       *framework.ServiceInformation `json:"origin"`
+	History      []HistoryEntry                `json:"history,omitempty"`
+}
+
+// saveSnapshot respalda en disco el estado de todos los servicios monitoreados
+// Se escribe en un archivo temporal que luego se renombra para no dejar un respaldo a medias
+func (su *ServiceUpdater) saveSnapshot() error {
+	su.updateServicesMux.Lock()
+	snapshot := make(map[string]serviceUpdaterDataSnapshot)
+	for k, v := range su.services {
+		s := serviceUpdaterDataSnapshot{
+			RegisterDate: v.registerDate,
+			LastUpdate:   v.lastUpdate,
+			LastAction:   v.lastAction,
+			ClusterID:    v.clusterID,
+			Origin:       v.origin,
+		}
+		if h, ok := su.history[k]; ok {
+			s.History = h.between(time.Time{}, time.Time{})
+		}
+		snapshot[k] = s
+	}
+	data, err := json.Marshal(snapshot)
+	su.updateServicesMux.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(su.snapshotFile), filepath.Base(su.snapshotFile)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), su.snapshotFile)
+}
+
+// loadSnapshot restaura el estado de los servicios desde el respaldo en disco
+// Se descartan los servicios de clusters que ya no estan configurados
+func (su *ServiceUpdater) loadSnapshot() error {
+	data, err := ioutil.ReadFile(su.snapshotFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	snapshot := make(map[string]serviceUpdaterDataSnapshot)
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	su.updateServicesMux.Lock()
+	defer su.updateServicesMux.Unlock()
+
+	for k, v := range snapshot {
+		if _, ok := su.clusters[v.ClusterID]; !ok || v.Origin == nil {
+			continue
+		}
+		su.services[k] = &ServiceUpdaterData{
+			registerDate: v.RegisterDate,
+			lastUpdate:   v.LastUpdate,
+			lastAction:   v.LastAction,
+			clusterID:    v.ClusterID,
+			origin:       v.Origin,
+		}
+
+		// Si el respaldo tiene mas transiciones que historySize se mantienen las mas recientes
+		if len(v.History) > 0 {
+			h := newServiceHistory(su.historySize)
+			for _, entry := range v.History {
+				h.add(entry)
+			}
+			su.history[k] = h
+		}
+	}
+
+	logger.Instance().Infof("Se restauraron %d servicios desde %s", len(su.services), su.snapshotFile)
+	return nil
+}
+
+// detachedSnapshot respalda periodicamente el estado de los servicios
+func (su *ServiceUpdater) detachedSnapshot() {
+	defer su.running.Done()
+
+	for {
+		select {
+		case <-su.ctx.Done():
+			return
+		case <-time.After(su.snapshotInterval):
+		}
+
+		if err := su.saveSnapshot(); err != nil {
+			logger.Instance().Errorf("No se pudo respaldar el estado de los servicios en %s: %s", su.snapshotFile, err.Error())
+		}
+	}
+}
@@ -2,6 +2,8 @@ package monitor
 
 // ServiceUpdaterSubscriber es una interfaz que deben implementar aquellos subscriptores
 // que desean recibir notificacions de los servicios manejados por ServiceUpdater
+// Update se llama mientras el ServiceUpdater mantiene tomado el estado de los servicios, por lo que
+// no debe bloquear, no debe llamar al ServiceUpdater y no debe retener los ServiceUpdaterData
 type ServiceUpdaterSubscriber interface {
 	ID() string
 	Update(map[string]*ServiceUpdaterData)
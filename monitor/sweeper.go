@@ -0,0 +1,90 @@
+package monitor
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ch3lo/overlord/logger"
+)
+
+// UpdaterStats resume la cantidad de servicios que maneja el ServiceUpdater
+type UpdaterStats struct {
+	Services int `json:"services"`
+	Removed  int `json:"removed"`
+	Purged   int `json:"purged"`
+}
+
+// Stats retorna la cantidad de servicios monitoreados, removidos y eliminados de memoria
+func (su *ServiceUpdater) Stats() UpdaterStats {
+	su.updateServicesMux.Lock()
+	defer su.updateServicesMux.Unlock()
+
+	stats := UpdaterStats{Services: len(su.services), Purged: su.purged}
+	for _, v := range su.services {
+		if v.lastAction == ServiceRemoved {
+			stats.Removed++
+		}
+	}
+	return stats
+}
+
+// sweep elimina de memoria los servicios removidos hace mas de removedRetention
+// y los mas antiguos si se supera maxRemoved. Se notifica a los subscriptores con
+// el estado ServicePurged para que liberen la informacion que mantengan
+func (su *ServiceUpdater) sweep(now time.Time) {
+	su.updateServicesMux.Lock()
+
+	var removed []string
+	for k, v := range su.services {
+		if v.lastAction == ServiceRemoved {
+			removed = append(removed, k)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return su.services[removed[i]].lastUpdate.Before(su.services[removed[j]].lastUpdate)
+	})
+
+	purgedServices := make(map[string]*ServiceUpdaterData)
+	for i, k := range removed {
+		expired := now.Sub(su.services[k].lastUpdate) > su.removedRetention
+		exceeded := len(removed)-i > su.maxRemoved
+		if !expired && !exceeded {
+			continue
+		}
+
+		// Se notifica una copia para no modificar la instancia que los subscriptores
+		// recibieron en notificaciones anteriores
+		purged := *su.services[k]
+		purged.previousAction = purged.lastAction
+		purged.lastAction = ServicePurged
+		purged.lastUpdate = now
+		purged.changes = nil
+		purgedServices[k] = &purged
+
+		delete(su.services, k)
+		delete(su.history, k)
+	}
+	su.purged += len(purgedServices)
+
+	su.updateServicesMux.Unlock()
+
+	if len(purgedServices) > 0 {
+		logger.Instance().Infof("Se eliminaron de memoria %d servicios removidos", len(purgedServices))
+		su.notify(purgedServices)
+	}
+}
+
+// detachedSweeper elimina periodicamente los servicios removidos
+func (su *ServiceUpdater) detachedSweeper() {
+	defer su.running.Done()
+
+	for {
+		select {
+		case <-su.ctx.Done():
+			return
+		case <-time.After(su.sweepInterval):
+		}
+
+		su.sweep(time.Now())
+	}
+}
@@ -0,0 +1,104 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Severity es la severidad de una alerta
+type Severity string
+
+const (
+	// SeverityCritical algun cluster no tiene instancias saludables del servicio
+	SeverityCritical Severity = "critical"
+	// SeverityWarning fallan chequeos pero el servicio sigue con instancias saludables
+	SeverityWarning Severity = "warning"
+	// SeverityInfo la alerta se resolvio
+	SeverityInfo Severity = "info"
+)
+
+// AlertFailure es un chequeo que no se cumplio
+// Observed es el valor observado y Threshold el valor esperado por el chequeo
+type AlertFailure struct {
+	Check     string `json:"check"`
+	Cluster   string `json:"cluster,omitempty"`
+	Host      string `json:"host,omitempty"`
+	Observed  int    `json:"observed"`
+	Threshold int    `json:"threshold"`
+}
+
+// ClusterCount es la cantidad de instancias de un servicio en un cluster
+type ClusterCount struct {
+	Instances int `json:"instances"`
+	Healthy   int `json:"healthy"`
+}
+
+// Alert es el evento que se envia a los notificadores cuando cambia la alerta de un Manager
+// Status es el estado de la alerta (Firing, Renotify o Resolved)
+// Checks son los chequeos involucrados, en una alerta resuelta son los que fallaban
+// RouteSeverity y RouteClusters son la severidad y los clusters con que se enruta la alerta.
+// Se acumulan desde que la alerta se activa para que Renotify y Resolved lleguen a los
+// mismos notificadores que recibieron la alerta activa. Son internos y no se serializan
+type Alert struct {
+	ManagerID        string                  `json:"manager_id"`
+	App              string                  `json:"app"`
+	Version          string                  `json:"version"`
+	Status           string                  `json:"status"`
+	Severity         Severity                `json:"severity"`
+	Checks           []string                `json:"checks,omitempty"`
+	Failures         []AlertFailure          `json:"failures,omitempty"`
+	Clusters         map[string]ClusterCount `json:"clusters,omitempty"`
+	ConsecutiveFails int                     `json:"consecutive_fails"`
+	Threshold        int                     `json:"threshold"`
+	Since            time.Time               `json:"since"`
+	Date             time.Time               `json:"date"`
+	RouteSeverity    Severity                `json:"-"`
+	RouteClusters    []string                `json:"-"`
+}
+
+// Subject retorna un resumen de una linea de la alerta
+func (a *Alert) Subject() string {
+	return fmt.Sprintf("[%s] %s version %s (%s)", a.Status, a.App, a.Version, a.Severity)
+}
+
+// Text retorna una descripcion legible de la alerta
+func (a *Alert) Text() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Servicio: %s\n", a.App)
+	fmt.Fprintf(&buf, "Version: %s\n", a.Version)
+	fmt.Fprintf(&buf, "Estado: %s\n", a.Status)
+	fmt.Fprintf(&buf, "Severidad: %s\n", a.Severity)
+	fmt.Fprintf(&buf, "Desde: %s\n", a.Since.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "Fecha: %s\n", a.Date.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "Chequeos fallidos consecutivos: %d (threshold %d)\n", a.ConsecutiveFails, a.Threshold)
+
+	if len(a.Failures) > 0 {
+		buf.WriteString("\nChequeos fallidos:\n")
+		for _, f := range a.Failures {
+			fmt.Fprintf(&buf, "  - %s", f.Check)
+			if f.Cluster != "" {
+				fmt.Fprintf(&buf, " cluster %s", f.Cluster)
+			}
+			if f.Host != "" {
+				fmt.Fprintf(&buf, " host %s", f.Host)
+			}
+			fmt.Fprintf(&buf, ": observado %d, umbral %d\n", f.Observed, f.Threshold)
+		}
+	}
+
+	if len(a.Clusters) > 0 {
+		clusters := make([]string, 0, len(a.Clusters))
+		for k := range a.Clusters {
+			clusters = append(clusters, k)
+		}
+		sort.Strings(clusters)
+
+		buf.WriteString("\nInstancias por cluster:\n")
+		for _, k := range clusters {
+			fmt.Fprintf(&buf, "  - %s: %d saludables de %d\n", k, a.Clusters[k].Healthy, a.Clusters[k].Instances)
+		}
+	}
+	return buf.String()
+}
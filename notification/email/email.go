@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/smtp"
+	"strings"
 
 	"github.com/ch3lo/overlord/logger"
 	"github.com/ch3lo/overlord/notification"
@@ -25,11 +26,13 @@ func (factory *emailCreator) Create(id string, params map[string]interface{}) (n
 }
 
 // EmailParameters encapsula los parametros de configuracion de Email
+// subject y body son templates que se aplican sobre la alerta, si son nil se usa el formato por defecto
 type parameters struct {
 	id       string
 	from     string
 	to       string
-	subject  string
+	subject  *notification.Template
+	body     *notification.Template
 	smtp     string
 	user     string
 	password string
@@ -53,18 +56,24 @@ func NewFromParameters(id string, params map[string]interface{}) (*Notification,
 		return nil, errors.New("Parametro de destinatario (to) no existe")
 	}
 
-	// TODO implementacion con autenticacion y subject
-	/*
-		subject := ""
-		if subjectTmp, ok := parameters["subject"]; ok {
-			subject = fmt.Sprint(subjectTmp)
-		}*/
+	// TODO implementacion con autenticacion
+	subject, err := notification.OptionalTemplate(params, "subject")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := notification.OptionalTemplate(params, "body")
+	if err != nil {
+		return nil, err
+	}
 
 	p := parameters{
-		id:   id,
-		smtp: fmt.Sprint(smtp),
-		from: fmt.Sprint(from),
-		to:   fmt.Sprint(to),
+		id:      id,
+		smtp:    fmt.Sprint(smtp),
+		from:    fmt.Sprint(from),
+		to:      fmt.Sprint(to),
+		subject: subject,
+		body:    body,
 	}
 	return New(p)
 }
@@ -77,6 +86,8 @@ func New(params parameters) (*Notification, error) {
 		address: params.smtp,
 		from:    params.from,
 		to:      params.to,
+		subject: params.subject,
+		body:    params.body,
 	}
 
 	return email, nil
@@ -89,6 +100,8 @@ type Notification struct {
 	address string
 	from    string
 	to      string
+	subject *notification.Template
+	body    *notification.Template
 }
 
 // ID retorna el identificador de este notificador
@@ -96,9 +109,46 @@ func (n *Notification) ID() string {
 	return n.id
 }
 
+// message construye el correo con sus cabeceras y el cuerpo de la alerta
+// Si no hay templates configurados se usa el asunto y la descripcion legible de la alerta
+func (n *Notification) message(alert *notification.Alert) ([]byte, error) {
+	subject := alert.Subject()
+	if n.subject != nil {
+		var err error
+		if subject, err = n.subject.Render(alert); err != nil {
+			return nil, err
+		}
+	}
+
+	body := alert.Text()
+	if n.body != nil {
+		var err error
+		if body, err = n.body.Render(alert); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", n.to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", headerReplacer.Replace(subject))
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+	return buf.Bytes(), nil
+}
+
+// headerReplacer reemplaza los saltos de linea de un valor de cabecera, para que un asunto
+// con saltos de linea no pueda agregar cabeceras ni adelantar el cuerpo del correo
+var headerReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
 // Notify notifica via email al destinatario
-func (n *Notification) Notify(data []byte) error {
+func (n *Notification) Notify(alert *notification.Alert) error {
 	logger.Instance().Infoln("Notificando via email")
+	data, err := n.message(alert)
+	if err != nil {
+		return err
+	}
 	logger.Instance().Debugf("Data: %s", string(data))
 
 	// Connect to the remote SMTP server.
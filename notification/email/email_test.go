@@ -0,0 +1,34 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ch3lo/overlord/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageSubjectWithoutNewLines(t *testing.T) {
+	assert := assert.New(t)
+
+	subject, err := notification.ParseTemplate("subject", "{{.App}} caido")
+	assert.Nil(err)
+	n := &Notification{from: "overlord@example.com", to: "ops@example.com", subject: subject}
+
+	alert := &notification.Alert{App: "app\r\nBcc: todos@example.com\n\r\nfalso", Version: "v1", Status: "Firing"}
+	data, err := n.message(alert)
+	assert.Nil(err)
+
+	headers := strings.SplitN(string(data), "\r\n\r\n", 2)[0]
+	assert.Equal([]string{
+		"From: overlord@example.com",
+		"To: ops@example.com",
+		"Subject: app Bcc: todos@example.com  falso caido",
+		"Content-Type: text/plain; charset=UTF-8",
+	}, strings.Split(headers, "\r\n"))
+
+	n.subject = nil
+	data, err = n.message(alert)
+	assert.Nil(err)
+	assert.NotContains(strings.SplitN(string(data), "\r\n\r\n", 2)[0], "\nBcc")
+}
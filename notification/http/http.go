@@ -2,6 +2,7 @@ package http
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -26,11 +27,14 @@ func (factory *httpCreator) Create(id string, params map[string]interface{}) (no
 }
 
 // parameters encapsula los parametros de configuracion de Email
+// body es un template que se aplica sobre la alerta, si es nil se envia la alerta como JSON
 type parameters struct {
-	id      string
-	url     string
-	headers string
-	method  string
+	id          string
+	url         string
+	headers     string
+	method      string
+	body        *notification.Template
+	contentType string
 }
 
 // NewFromParameters construye un Notification a partir de un mapeo de parámetros
@@ -46,10 +50,22 @@ func NewFromParameters(id string, params map[string]interface{}) (*Notification,
 		return nil, errors.New("Parametro method no existe")
 	}
 
+	body, err := notification.OptionalTemplate(params, "body")
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := "application/json"
+	if ct, ok := params["contentType"]; ok && fmt.Sprint(ct) != "" {
+		contentType = fmt.Sprint(ct)
+	}
+
 	p := parameters{
-		id:     id,
-		url:    fmt.Sprint(url),
-		method: fmt.Sprint(method),
+		id:          id,
+		url:         fmt.Sprint(url),
+		method:      fmt.Sprint(method),
+		body:        body,
+		contentType: contentType,
 	}
 	return New(p)
 }
@@ -58,9 +74,11 @@ func NewFromParameters(id string, params map[string]interface{}) (*Notification,
 func New(params parameters) (*Notification, error) {
 
 	http := &Notification{
-		id:     params.id,
-		url:    params.url,
-		method: params.method,
+		id:          params.id,
+		url:         params.url,
+		method:      params.method,
+		body:        params.body,
+		contentType: params.contentType,
 	}
 
 	return http, nil
@@ -69,9 +87,11 @@ func New(params parameters) (*Notification, error) {
 // Notification es una implementacion de notification.Notification
 // Permite la comunicacion via email
 type Notification struct {
-	id     string
-	url    string
-	method string
+	id          string
+	url         string
+	method      string
+	body        *notification.Template
+	contentType string
 }
 
 // ID retorna el identificador de este notificador
@@ -79,14 +99,29 @@ func (n *Notification) ID() string {
 	return n.id
 }
 
+// payload retorna el cuerpo del request, el template configurado o la alerta como JSON
+func (n *Notification) payload(alert *notification.Alert) ([]byte, error) {
+	if n.body != nil {
+		body, err := n.body.Render(alert)
+		return []byte(body), err
+	}
+	return json.Marshal(alert)
+}
+
 // Notify notifica via http al endpoint configurado
-func (n *Notification) Notify(data []byte) error {
+func (n *Notification) Notify(alert *notification.Alert) error {
 	logger.Instance().Infoln("Notificando via http")
+	data, err := n.payload(alert)
+	if err != nil {
+		return err
+	}
 	logger.Instance().Debugf("Data: %s", string(data))
 
-	//var query = []byte(`your query`)
-	req, err := http.NewRequest("POST", n.url, bytes.NewBuffer(data))
-	//req.Header.Set("Content-Type", "text/plain")
+	req, err := http.NewRequest(n.method, n.url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", n.contentType)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/notification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestHttp(t *testing.T) {
+	suite.Run(t, new(HttpSuite))
+}
+
+type HttpSuite struct {
+	suite.Suite
+	alert *notification.Alert
+}
+
+func (suite *HttpSuite) SetupTest() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+	date, _ := time.Parse(time.RFC3339, "2012-11-01T22:08:41Z")
+	suite.alert = &notification.Alert{
+		ManagerID: "app#v1",
+		App:       "app",
+		Version:   "v1",
+		Status:    "Firing",
+		Severity:  notification.SeverityCritical,
+		Failures:  []notification.AlertFailure{{Check: "min-instances", Cluster: "wdc", Observed: 0, Threshold: 2}},
+		Clusters:  map[string]notification.ClusterCount{"wdc": {Instances: 1}},
+		Since:     date,
+		Date:      date,
+	}
+}
+
+func (suite *HttpSuite) TestNotifyJson() {
+	assert := assert.New(suite.T())
+
+	var received notification.Alert
+	var contentType, method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		method = r.Method
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	n, err := NewFromParameters("http-id", map[string]interface{}{"url": server.URL, "method": "PUT"})
+	assert.Nil(err)
+	assert.Nil(n.Notify(suite.alert))
+	assert.Equal("application/json", contentType)
+	assert.Equal("PUT", method)
+	assert.Equal(*suite.alert, received)
+}
+
+func (suite *HttpSuite) TestNotifyJsonWithoutRoute() {
+	assert := assert.New(suite.T())
+
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	suite.alert.RouteSeverity = notification.SeverityCritical
+	suite.alert.RouteClusters = []string{"wdc"}
+	n, err := NewFromParameters("http-id", map[string]interface{}{"url": server.URL, "method": "POST"})
+	assert.Nil(err)
+	assert.Nil(n.Notify(suite.alert))
+	assert.NotContains(string(body), "route")
+}
+
+func (suite *HttpSuite) TestNotifyError() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n, _ := NewFromParameters("http-id", map[string]interface{}{"url": server.URL, "method": "POST"})
+	assert.NotNil(suite.T(), n.Notify(suite.alert))
+}
+
+func (suite *HttpSuite) TestNotifyTemplate() {
+	assert := assert.New(suite.T())
+
+	var body []byte
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	n, err := NewFromParameters("http-id", map[string]interface{}{
+		"url":         server.URL,
+		"method":      "POST",
+		"contentType": "text/plain",
+		"body":        "{{.Severity}}: {{.App}} {{.Version}}",
+	})
+	assert.Nil(err)
+	assert.Nil(n.Notify(suite.alert))
+	assert.Equal("text/plain", contentType)
+	assert.Equal("critical: app v1", string(body))
+}
+
+func (suite *HttpSuite) TestInvalidTemplate() {
+	_, err := NewFromParameters("http-id", map[string]interface{}{"url": "http://localhost", "method": "POST", "body": "{{.Nope}}"})
+	assert.IsType(suite.T(), &notification.InvalidTemplate{}, err)
+}
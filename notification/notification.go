@@ -1,8 +1,9 @@
 package notification
 
 // Notification es una interfaz que deben implementar los notificadores
+// Cada notificador debe representar la alerta en su propio formato
 // Para un ejemplo ir a notification.Email
 type Notification interface {
 	ID() string
-	Notify(data []byte) error
+	Notify(alert *Alert) error
 }
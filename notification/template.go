@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// templateFuncs son las funciones disponibles en los templates de notificacion
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// sampleAlert es la alerta con la que se validan los templates al crearlos
+var sampleAlert = &Alert{
+	ManagerID: "app#v1",
+	App:       "app",
+	Version:   "v1",
+	Status:    "Firing",
+	Severity:  SeverityCritical,
+	Failures: []AlertFailure{
+		{Check: "min-instances", Cluster: "cluster", Observed: 0, Threshold: 1},
+	},
+	Clusters:         map[string]ClusterCount{"cluster": {Instances: 1}},
+	ConsecutiveFails: 1,
+	Threshold:        1,
+	Since:            time.Now(),
+	Date:             time.Now(),
+}
+
+// Template es un template de texto (text/template) que se aplica sobre una Alert
+type Template struct {
+	template *template.Template
+}
+
+// ParseTemplate compila un template y lo valida aplicandolo sobre una alerta de ejemplo
+// para detectar al iniciar los campos inexistentes
+func ParseTemplate(name string, text string) (*Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, &InvalidTemplate{Name: name, Message: err.Error()}
+	}
+
+	tmpl := &Template{template: t}
+	if _, err := tmpl.Render(sampleAlert); err != nil {
+		return nil, &InvalidTemplate{Name: name, Message: err.Error()}
+	}
+	return tmpl, nil
+}
+
+// Render aplica el template sobre la alerta
+func (t *Template) Render(alert *Alert) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, alert); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// OptionalTemplate compila el template del parametro name si existe
+// Si el parametro no existe o es vacio retorna nil
+func OptionalTemplate(params map[string]interface{}, name string) (*Template, error) {
+	text, ok := params[name]
+	if !ok || fmt.Sprint(text) == "" {
+		return nil, nil
+	}
+	return ParseTemplate(name, fmt.Sprint(text))
+}
+
+// InvalidTemplate sucede cuando un template de notificacion no se puede compilar o aplicar
+type InvalidTemplate struct {
+	Name    string
+	Message string
+}
+
+func (err InvalidTemplate) Error() string {
+	return fmt.Sprintf("Template %s invalido: %s", err.Name, err.Message)
+}
@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestTemplate(t *testing.T) {
+	suite.Run(t, new(TemplateSuite))
+}
+
+type TemplateSuite struct {
+	suite.Suite
+}
+
+func (suite *TemplateSuite) TestRender() {
+	assert := assert.New(suite.T())
+	tmpl, err := ParseTemplate("subject", `{{.Status}} {{.App}}{{range .Failures}} {{.Check}}@{{.Cluster}}{{end}}`)
+	assert.Nil(err)
+
+	text, err := tmpl.Render(sampleAlert)
+	assert.Nil(err)
+	assert.Equal("Firing app min-instances@cluster", text)
+}
+
+func (suite *TemplateSuite) TestJsonFunc() {
+	assert := assert.New(suite.T())
+	tmpl, err := ParseTemplate("body", `{"text": {{json .Subject}}}`)
+	assert.Nil(err)
+
+	text, _ := tmpl.Render(sampleAlert)
+	assert.Equal(`{"text": "[Firing] app version v1 (critical)"}`, text)
+}
+
+func (suite *TemplateSuite) TestInvalid() {
+	assert := assert.New(suite.T())
+	_, err := ParseTemplate("subject", `{{.Status`)
+	assert.IsType(&InvalidTemplate{}, err)
+
+	_, err = ParseTemplate("subject", `{{.Unknown}}`)
+	assert.IsType(&InvalidTemplate{}, err)
+
+	_, err = ParseTemplate("subject", `{{undefined .App}}`)
+	assert.IsType(&InvalidTemplate{}, err)
+}
+
+func (suite *TemplateSuite) TestOptional() {
+	assert := assert.New(suite.T())
+	tmpl, err := OptionalTemplate(map[string]interface{}{"body": ""}, "body")
+	assert.Nil(tmpl)
+	assert.Nil(err)
+
+	tmpl, err = OptionalTemplate(map[string]interface{}{}, "body")
+	assert.Nil(tmpl)
+	assert.Nil(err)
+
+	tmpl, err = OptionalTemplate(map[string]interface{}{"body": "{{.App}}"}, "body")
+	assert.NotNil(tmpl)
+	assert.Nil(err)
+}
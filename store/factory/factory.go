@@ -0,0 +1,49 @@
+package factory
+
+import (
+	"fmt"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/store"
+)
+
+// storeFactories almacena una mapeo entre un tipo de store y su constructor
+var storeFactories = make(map[string]StoreFactory)
+
+// StoreFactory es una interfaz para crear un Store
+// Cada Store debe implementar estar interfaz y además llamar el metodo Register
+type StoreFactory interface {
+	Create(parameters map[string]interface{}) (store.Store, error)
+}
+
+// Register permite a una implementación de Store estar disponible mediante un id que representa el tipo de store
+func Register(name string, factory StoreFactory) {
+	if factory == nil {
+		logger.Instance().Fatal("Se debe pasar como argumento un StoreFactory")
+	}
+	_, registered := storeFactories[name]
+	if registered {
+		logger.Instance().Fatalf("StoreFactory %s ya está registrado", name)
+	}
+
+	storeFactories[name] = factory
+}
+
+// Create crea un store a partir de su tipo.
+// Si el store no estaba registrado se retornará un InvalidStore
+func Create(name string, parameters map[string]interface{}) (store.Store, error) {
+	storeFactory, ok := storeFactories[name]
+	if !ok {
+		return nil, InvalidStore{name}
+	}
+	return storeFactory.Create(parameters)
+}
+
+// InvalidStore sucede cuando se instenta construir un Store no registrado
+type InvalidStore struct {
+	Name string
+}
+
+func (err InvalidStore) Error() string {
+	return fmt.Sprintf("Store no esta registrado: %s", err.Name)
+}
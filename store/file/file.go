@@ -0,0 +1,179 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/manager/service"
+	"github.com/ch3lo/overlord/store"
+	"github.com/ch3lo/overlord/store/factory"
+)
+
+const storeID = "file"
+
+// defaultPath es el archivo que se utiliza cuando no se configura el parametro path
+const defaultPath = "overlord-services.json"
+
+func init() {
+	factory.Register(storeID, &fileCreator{})
+}
+
+// fileCreator implementa la interfaz factory.StoreFactory
+type fileCreator struct{}
+
+func (factory *fileCreator) Create(params map[string]interface{}) (store.Store, error) {
+	return NewFromParameters(params)
+}
+
+// NewFromParameters construye un Store a partir de un mapeo de parámetros
+func NewFromParameters(params map[string]interface{}) (*Store, error) {
+	path := defaultPath
+	if p, ok := params["path"]; ok && fmt.Sprint(p) != "" {
+		path = fmt.Sprint(p)
+	}
+
+	return New(path)
+}
+
+// New construye un nuevo Store que persiste los registros en el archivo path
+func New(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		services: make(map[string]service.Parameters),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Instance().Infof("El archivo de registros %s no existe, se creara al registrar un servicio", path)
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var services []service.Parameters
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("No se pudo leer el archivo de registros %s: %s", path, err.Error())
+	}
+
+	for _, v := range services {
+		s.services[key(v.ID, v.Version)] = v
+	}
+
+	return s, nil
+}
+
+// Store es una implementacion de store.Store
+// Persiste los registros de servicios como un documento JSON en el sistema de archivos
+type Store struct {
+	mux      sync.Mutex
+	path     string
+	services map[string]service.Parameters
+}
+
+// ID retorna el identificador de este store
+func (s *Store) ID() string {
+	return storeID
+}
+
+// Save persiste el registro de un servicio, reemplazandolo si ya existia
+func (s *Store) Save(params service.Parameters) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	k := key(params.ID, params.Version)
+	previous, existed := s.services[k]
+	s.services[k] = params
+
+	if err := s.flush(); err != nil {
+		if existed {
+			s.services[k] = previous
+		} else {
+			delete(s.services, k)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete elimina el registro de un servicio
+func (s *Store) Delete(serviceID string, version string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	k := key(serviceID, version)
+	previous, existed := s.services[k]
+	if !existed {
+		return nil
+	}
+	delete(s.services, k)
+
+	if err := s.flush(); err != nil {
+		s.services[k] = previous
+		return err
+	}
+	return nil
+}
+
+// FindAll retorna todos los registros persistidos ordenados por servicio y version
+func (s *Store) FindAll() ([]service.Parameters, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.sorted(), nil
+}
+
+func (s *Store) sorted() []service.Parameters {
+	keys := make([]string, 0, len(s.services))
+	for k := range s.services {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	services := make([]service.Parameters, 0, len(keys))
+	for _, k := range keys {
+		services = append(services, s.services[k])
+	}
+	return services
+}
+
+// flush escribe el estado completo en un archivo temporal y luego lo renombra
+// para que un fallo a mitad de escritura no corrompa los registros existentes
+func (s *Store) flush() error {
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func key(serviceID string, version string) string {
+	return serviceID + "#" + version
+}
@@ -0,0 +1,105 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ch3lo/overlord/logger"
+	"github.com/ch3lo/overlord/manager/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileStore(t *testing.T) {
+	suite.Run(t, new(FileStoreSuite))
+}
+
+type FileStoreSuite struct {
+	suite.Suite
+	dir  string
+	path string
+}
+
+func (suite *FileStoreSuite) SetupTest() {
+	logger.Configure(logger.Config{Level: "error", Formatter: "text", Output: "console"})
+	dir, err := ioutil.TempDir("", "overlord-store")
+	assert.Nil(suite.T(), err)
+	suite.dir = dir
+	suite.path = filepath.Join(dir, "services.json")
+}
+
+func (suite *FileStoreSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *FileStoreSuite) params(id string, version string) service.Parameters {
+	return service.Parameters{
+		ID:      id,
+		Version: version,
+		Constraints: service.ConstraintsParams{
+			ImageName:              "registry.com/" + id,
+			MinInstancesPerCluster: map[string]int{"dal": 2},
+		},
+	}
+}
+
+func (suite *FileStoreSuite) TestNewWithoutFile() {
+	assert := assert.New(suite.T())
+	s, err := New(suite.path)
+	assert.Nil(err)
+	all, err := s.FindAll()
+	assert.Nil(err)
+	assert.Len(all, 0)
+}
+
+func (suite *FileStoreSuite) TestDefaultPath() {
+	assert := assert.New(suite.T())
+	s, err := NewFromParameters(map[string]interface{}{})
+	assert.Nil(err)
+	assert.Equal(defaultPath, s.path)
+}
+
+func (suite *FileStoreSuite) TestSaveAndReload() {
+	assert := assert.New(suite.T())
+	s, err := New(suite.path)
+	assert.Nil(err)
+	assert.Nil(s.Save(suite.params("app2", "1")))
+	assert.Nil(s.Save(suite.params("app1", "2")))
+	assert.Nil(s.Save(suite.params("app1", "1")))
+
+	reloaded, err := New(suite.path)
+	assert.Nil(err)
+	all, err := reloaded.FindAll()
+	assert.Nil(err)
+	assert.Equal([]service.Parameters{
+		suite.params("app1", "1"),
+		suite.params("app1", "2"),
+		suite.params("app2", "1"),
+	}, all)
+}
+
+func (suite *FileStoreSuite) TestDelete() {
+	assert := assert.New(suite.T())
+	s, err := New(suite.path)
+	assert.Nil(err)
+	assert.Nil(s.Save(suite.params("app1", "1")))
+	assert.Nil(s.Save(suite.params("app1", "2")))
+	assert.Nil(s.Delete("app1", "1"))
+	assert.Nil(s.Delete("app1", "99"))
+
+	reloaded, err := New(suite.path)
+	assert.Nil(err)
+	all, err := reloaded.FindAll()
+	assert.Nil(err)
+	assert.Equal([]service.Parameters{suite.params("app1", "2")}, all)
+}
+
+func (suite *FileStoreSuite) TestCorruptFile() {
+	assert := assert.New(suite.T())
+	assert.Nil(ioutil.WriteFile(suite.path, []byte("{no es json"), 0644))
+	s, err := New(suite.path)
+	assert.Error(err)
+	assert.Nil(s)
+}
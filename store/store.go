@@ -0,0 +1,13 @@
+package store
+
+import "github.com/ch3lo/overlord/manager/service"
+
+// Store es una interfaz que deben implementar los almacenes de registros de servicios
+// Permite recuperar los managers registrados luego de un reinicio
+// Para un ejemplo ir a store/file
+type Store interface {
+	ID() string
+	Save(params service.Parameters) error
+	Delete(serviceID string, version string) error
+	FindAll() ([]service.Parameters, error)
+}